@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+)
+
+// CategoryController - categories of feeds
+type CategoryController struct {
+	service *services.CategoryService
+	config  *models.Config
+}
+
+// NewCategoryCtrl - init service
+func NewCategoryCtrl(cfg *models.Config) *CategoryController {
+	ctrl := new(CategoryController)
+	ctrl.config = cfg
+	ctrl.service = services.NewCategoryService(cfg)
+
+	return ctrl
+}
+
+// GetAll - get categories with unread counts
+func (ctrl *CategoryController) GetAll(w http.ResponseWriter, r *http.Request) {
+	claims := getClaims(r)
+	categories := ctrl.service.GetAll(claims.Id)
+
+	json.NewEncoder(w).Encode(categories)
+}
+
+// Create - add category
+func (ctrl *CategoryController) Create(w http.ResponseWriter, r *http.Request) {
+	data := models.CategoryUpdateData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	category, err := ctrl.service.Create(data.Name, claims.Id)
+
+	if err != nil {
+		log.Println("create category error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// Update - rename category or mark it as read
+func (ctrl *CategoryController) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := models.CategoryUpdateData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	category := ctrl.service.Update(id, data, claims.Id)
+
+	if category == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(category)
+}
+
+// Delete - delete category, feeds are kept
+func (ctrl *CategoryController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if err := ctrl.service.Delete(id, claims.Id); err != nil {
+		log.Println("delete category error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctrl.GetAll(w, r)
+}
+
+// GetArticles - get articles of category feeds
+func (ctrl *CategoryController) GetArticles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(r.FormValue("page"))
+
+	if err != nil {
+		log.Println("page is invalid:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if ctrl.service.Get(id, claims.Id).Id == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	articles := ctrl.service.GetArticles(id, claims.Id, page)
+
+	json.NewEncoder(w).Encode(articles)
+}
+
+// MarkRead - mark all articles of category as read
+func (ctrl *CategoryController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if ctrl.service.Get(id, claims.Id).Id == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ctrl.service.MarkRead(id, claims.Id)
+	ctrl.GetAll(w, r)
+}
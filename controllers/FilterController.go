@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+)
+
+// FilterController - filter rules of new items
+type FilterController struct {
+	service *services.FilterService
+	config  *models.Config
+}
+
+// NewFilterCtrl - init service
+func NewFilterCtrl(cfg *models.Config) *FilterController {
+	ctrl := new(FilterController)
+	ctrl.config = cfg
+	ctrl.service = services.NewFilterService(cfg)
+
+	return ctrl
+}
+
+// GetAll - get filter rules
+func (ctrl *FilterController) GetAll(w http.ResponseWriter, r *http.Request) {
+	claims := getClaims(r)
+	rules := ctrl.service.GetAll(claims.Id)
+
+	json.NewEncoder(w).Encode(rules)
+}
+
+// Create - add filter rule
+func (ctrl *FilterController) Create(w http.ResponseWriter, r *http.Request) {
+	data := models.FilterRuleData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	rule, err := ctrl.service.Create(data, claims.Id)
+
+	if err != nil {
+		log.Println("create filter rule error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// Update - change filter rule
+func (ctrl *FilterController) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := models.FilterRuleData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	rule, err := ctrl.service.Update(id, data, claims.Id)
+
+	if err != nil {
+		log.Println("update filter rule error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if rule == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rule)
+}
+
+// Delete - delete filter rule
+func (ctrl *FilterController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if err := ctrl.service.Delete(id, claims.Id); err != nil {
+		log.Println("delete filter rule error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctrl.GetAll(w, r)
+}
+
+// Test - check filter rule against latest items, count of items is set by "count" parameter
+func (ctrl *FilterController) Test(w http.ResponseWriter, r *http.Request) {
+	data := models.FilterRuleData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	if value := r.FormValue("count"); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	claims := getClaims(r)
+	result, err := ctrl.service.Test(data, claims.Id, count)
+
+	if err != nil {
+		log.Println("test filter rule error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"newshub-server/models"
+	"newshub-server/services"
+)
+
+// RetentionController - retention policy of user items
+type RetentionController struct {
+	service *services.RetentionService
+	config  *models.Config
+}
+
+// NewRetentionCtrl - init service
+func NewRetentionCtrl(cfg *models.Config) *RetentionController {
+	ctrl := new(RetentionController)
+	ctrl.config = cfg
+	ctrl.service = services.NewRetentionService(cfg)
+
+	return ctrl
+}
+
+// Report - get items which will be removed on next retention run
+func (ctrl *RetentionController) Report(w http.ResponseWriter, r *http.Request) {
+	claims := getClaims(r)
+	report, err := ctrl.service.Purge(claims.Id, true)
+
+	if err != nil {
+		log.Println("retention report error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
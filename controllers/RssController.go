@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -14,8 +15,10 @@ import (
 
 // RssController - request handlers
 type RssController struct {
-	service *services.RssService
-	config  *models.Config
+	service       *services.RssService
+	importService *services.ImportService
+	searchService *services.SavedSearchService
+	config        *models.Config
 }
 
 // NewRssCtrl - init service
@@ -23,14 +26,16 @@ func NewRssCtrl(cfg *models.Config) *RssController {
 	ctrl := new(RssController)
 	ctrl.config = cfg
 	ctrl.service = services.NewRssService(cfg)
+	ctrl.importService = services.NewImportService(cfg)
+	ctrl.searchService = services.NewSavedSearchService(cfg)
 
 	return ctrl
 }
 
-// GetAll - get feed list
+// GetAll - get feed list, saved searches are listed after feeds
 func (ctrl *RssController) GetAll(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
-	feeds := ctrl.service.GetRss(claims.Id)
+	feeds := append(ctrl.service.GetRss(claims.Id), ctrl.searchService.GetFeeds(claims.Id)...)
 
 	json.NewEncoder(w).Encode(feeds)
 }
@@ -54,6 +59,20 @@ func (ctrl *RssController) GetArticles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	claims := getClaims(r)
+
+	// negative id is id of saved search
+	if id < 0 {
+		articles := ctrl.searchService.GetArticles(-id, claims.Id, page)
+
+		if articles == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(articles)
+		return
+	}
+
 	feed := ctrl.service.GetArticles(id, claims.Id, page)
 
 	json.NewEncoder(w).Encode(feed)
@@ -113,10 +132,60 @@ func (ctrl *RssController) AddFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctrl.service.AddFeed(filters.Url, claims.Id)
+	candidates, err := ctrl.service.AddFeed(filters.Url, claims.Id)
+
+	if err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	if len(candidates) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultipleChoices)
+		json.NewEncoder(w).Encode(candidates)
+		return
+	}
+
 	ctrl.GetAll(w, r)
 }
 
+// Preview - get feed content by url without subscription
+func (ctrl *RssController) Preview(w http.ResponseWriter, r *http.Request) {
+	filters := models.RssFilters{}
+
+	if err := json.NewDecoder(r.Body).Decode(&filters); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if filters.Url == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	preview, err := ctrl.service.Preview(filters.Url)
+
+	if err != nil {
+		writeFeedError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// writeFeedError - send reason why url can not be used as feed
+func writeFeedError(w http.ResponseWriter, err error) {
+	var feedErr *services.FeedError
+
+	if !errors.As(err, &feedErr) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(feedErr)
+}
+
 // Delete - delete feed
 func (ctrl *RssController) Delete(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
@@ -132,7 +201,7 @@ func (ctrl *RssController) Delete(w http.ResponseWriter, r *http.Request) {
 	ctrl.GetAll(w, r)
 }
 
-// UploadOpml - upload, parse OPML and update feeds
+// UploadOpml - upload OPML and start import of feeds in background
 func (ctrl *RssController) UploadOpml(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
 	file, _, err := r.FormFile("file")
@@ -151,8 +220,47 @@ func (ctrl *RssController) UploadOpml(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctrl.service.Import(data, claims.Id)
-	ctrl.GetAll(w, r)
+	job, err := ctrl.importService.Start(data, claims.Id)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/rss/opml/jobs/"+job.Id)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetImportJob - get progress of OPML import
+func (ctrl *RssController) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	claims := getClaims(r)
+	job := ctrl.importService.Get(vars["id"], claims.Id)
+
+	if job == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelImportJob - stop OPML import, feeds added before are kept
+func (ctrl *RssController) CancelImportJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	claims := getClaims(r)
+	job := ctrl.importService.Cancel(vars["id"], claims.Id)
+
+	if job == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
 }
 
 // CreateOpml - create OPML file
@@ -160,6 +268,7 @@ func (ctrl *RssController) CreateOpml(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
 	opmlBytes := ctrl.service.Export(claims.Id)
 
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
 	w.Write(opmlBytes)
 }
 
@@ -188,6 +297,27 @@ func (ctrl *RssController) SetNewFeedName(w http.ResponseWriter, r *http.Request
 	w.Write(jsonData)
 }
 
+// GetStatus - get feed update health
+func (ctrl *RssController) GetStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	status := ctrl.service.GetStatus(id, claims.Id)
+
+	if status == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
 // GetBookmarks - get bookmark list
 func (ctrl *RssController) GetBookmarks(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
@@ -220,6 +350,28 @@ func (ctrl *RssController) Search(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(ctrl.service.Search(searchString, isBookmark, feedID, claims.Id))
 }
 
+// MarkRead - mark articles of all feeds, feed, category or search result as read
+func (ctrl *RssController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	data := models.MarkReadData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	count, err := ctrl.service.MarkRead(data, claims.Id)
+
+	if err != nil {
+		log.Println("mark read error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MarkReadResult{Count: count})
+}
+
 // UpdateArticle - update by id
 func (ctrl *RssController) UpdateArticle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -248,3 +400,57 @@ func (ctrl *RssController) UpdateArticle(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// GetPlayback - get playback position of enclosure
+func (ctrl *RssController) GetPlayback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	position := ctrl.service.GetPlayback(id, claims.Id)
+
+	if position == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(position)
+}
+
+// SavePlayback - save playback position of enclosure
+func (ctrl *RssController) SavePlayback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := models.PlaybackUpdateData{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data.EnclosureId = id
+	if data.Position < 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	claims := getClaims(r)
+	position := ctrl.service.SavePlayback(claims.Id, data)
+
+	if position == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(position)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+)
+
+// SavedSearchController - saved searches shown as virtual feeds
+type SavedSearchController struct {
+	service *services.SavedSearchService
+	config  *models.Config
+}
+
+// NewSavedSearchCtrl - init service
+func NewSavedSearchCtrl(cfg *models.Config) *SavedSearchController {
+	ctrl := new(SavedSearchController)
+	ctrl.config = cfg
+	ctrl.service = services.NewSavedSearchService(cfg)
+
+	return ctrl
+}
+
+// GetAll - get saved searches
+func (ctrl *SavedSearchController) GetAll(w http.ResponseWriter, r *http.Request) {
+	claims := getClaims(r)
+	searches := ctrl.service.GetAll(claims.Id)
+
+	json.NewEncoder(w).Encode(searches)
+}
+
+// Create - save search
+func (ctrl *SavedSearchController) Create(w http.ResponseWriter, r *http.Request) {
+	data := models.SavedSearchData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	search, err := ctrl.service.Create(data, claims.Id)
+
+	if err != nil {
+		log.Println("create saved search error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(search)
+}
+
+// Update - change saved search
+func (ctrl *SavedSearchController) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := models.SavedSearchData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	search, err := ctrl.service.Update(id, data, claims.Id)
+
+	if err != nil {
+		log.Println("update saved search error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if search == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(search)
+}
+
+// Delete - delete saved search
+func (ctrl *SavedSearchController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if err := ctrl.service.Delete(id, claims.Id); err != nil {
+		log.Println("delete saved search error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctrl.GetAll(w, r)
+}
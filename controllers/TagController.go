@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+)
+
+// TagController - user tags of articles
+type TagController struct {
+	service *services.TagService
+	config  *models.Config
+}
+
+// NewTagCtrl - init service
+func NewTagCtrl(cfg *models.Config) *TagController {
+	ctrl := new(TagController)
+	ctrl.config = cfg
+	ctrl.service = services.NewTagService(cfg)
+
+	return ctrl
+}
+
+// GetAll - get tags with count of articles
+func (ctrl *TagController) GetAll(w http.ResponseWriter, r *http.Request) {
+	claims := getClaims(r)
+	tags := ctrl.service.GetAll(claims.Id)
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+// Delete - delete tag from all articles
+func (ctrl *TagController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if err := ctrl.service.Delete(id, claims.Id); err != nil {
+		log.Println("delete tag error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctrl.GetAll(w, r)
+}
+
+// GetArticles - get articles with tag
+func (ctrl *TagController) GetArticles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(r.FormValue("page"))
+
+	if err != nil {
+		log.Println("page is invalid:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+
+	if ctrl.service.Get(id, claims.Id).Id == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	articles := ctrl.service.GetArticles(id, claims.Id, page)
+
+	json.NewEncoder(w).Encode(articles)
+}
+
+// AddTag - add tag to article
+func (ctrl *TagController) AddTag(w http.ResponseWriter, r *http.Request) {
+	articleID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := models.TagData{}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	tags, err := ctrl.service.AddTag(articleID, data.Name, claims.Id)
+
+	if err != nil {
+		log.Println("add tag error:", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if tags == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+// RemoveTag - remove tag from article
+func (ctrl *TagController) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	articleID, err := strconv.ParseInt(vars["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tagID, err := strconv.ParseInt(vars["tag_id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	tags := ctrl.service.RemoveTag(articleID, tagID, claims.Id)
+
+	if tags == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
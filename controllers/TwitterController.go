@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -60,6 +61,27 @@ func (ctrl *TwitterController) GetNews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// AddNews - save tweets sent by collector, tweets are filtered by user rules
+func (ctrl *TwitterController) AddNews(w http.ResponseWriter, r *http.Request) {
+	var news []models.TwitterNews
+
+	if err := json.NewDecoder(r.Body).Decode(&news); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	count, err := ctrl.service.AddNews(claims.Id, news)
+
+	if err != nil {
+		log.Println("add tweets error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.AddNewsResult{Count: count})
+}
+
 func (ctrl *TwitterController) GetSources(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
 	sources := ctrl.service.GetAllSources(claims.Id)
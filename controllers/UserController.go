@@ -141,6 +141,7 @@ func (ctrl *UserController) GetUserSettings(w http.ResponseWriter, r *http.Reque
 	result := models.SettingsData{
 		VkNewsEnabled:        settings.VkNewsEnabled,
 		MarkSameRead:         settings.MarkSameRead,
+		MarkSameTitle:        settings.MarkSameTitle,
 		RssEnabled:           settings.RssEnabled,
 		ShowPreviewButton:    settings.ShowPreviewButton,
 		ShowReadButton:       settings.ShowReadButton,
@@ -154,6 +155,8 @@ func (ctrl *UserController) GetUserSettings(w http.ResponseWriter, r *http.Reque
 		TwitterSimpleVersion: settings.TwitterSimpleVersion,
 		ShowLinkButton:       settings.ShowLinkButton,
 		ShowBookmarkButton:   settings.ShowBookmarkButton,
+		RetentionDays:        settings.RetentionDays,
+		RetentionCount:       settings.RetentionCount,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -194,6 +197,7 @@ func (ctrl *UserController) SaveSettings(w http.ResponseWriter, r *http.Request)
 	result := models.SettingsData{
 		VkNewsEnabled:        settings.VkNewsEnabled,
 		MarkSameRead:         settings.MarkSameRead,
+		MarkSameTitle:        settings.MarkSameTitle,
 		RssEnabled:           settings.RssEnabled,
 		ShowPreviewButton:    settings.ShowPreviewButton,
 		ShowReadButton:       settings.ShowReadButton,
@@ -207,6 +211,8 @@ func (ctrl *UserController) SaveSettings(w http.ResponseWriter, r *http.Request)
 		TwitterSimpleVersion: settings.TwitterSimpleVersion,
 		ShowLinkButton:       settings.ShowLinkButton,
 		ShowBookmarkButton:   settings.ShowBookmarkButton,
+		RetentionDays:        settings.RetentionDays,
+		RetentionCount:       settings.RetentionCount,
 	}
 
 	settingsBytes, err := json.Marshal(result)
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -59,6 +60,27 @@ func (ctrl *VkController) GetNews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// AddNews - save posts sent by collector, posts are filtered by user rules
+func (ctrl *VkController) AddNews(w http.ResponseWriter, r *http.Request) {
+	var news []models.VkNews
+
+	if err := json.NewDecoder(r.Body).Decode(&news); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := getClaims(r)
+	count, err := ctrl.service.AddNews(claims.Id, news)
+
+	if err != nil {
+		log.Println("add vk news error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.AddNewsResult{Count: count})
+}
+
 func (ctrl *VkController) Search(w http.ResponseWriter, r *http.Request) {
 	claims := getClaims(r)
 	groupID := int64(0)
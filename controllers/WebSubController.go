@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxPushSize - max size of content pushed by hub
+const maxPushSize = 10 << 20
+
+// WebSubController - callbacks for WebSub hubs
+type WebSubController struct {
+	service *services.WebSubService
+	config  *models.Config
+}
+
+// NewWebSubCtrl - init service
+func NewWebSubCtrl(cfg *models.Config) *WebSubController {
+	ctrl := new(WebSubController)
+	ctrl.config = cfg
+	ctrl.service = services.NewWebSubService(cfg)
+
+	return ctrl
+}
+
+// Verify - confirm subscription or unsubscription
+func (ctrl *WebSubController) Verify(w http.ResponseWriter, r *http.Request) {
+	feedID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	lease, _ := strconv.Atoi(query.Get("hub.lease_seconds"))
+
+	if !ctrl.service.Verify(feedID, query.Get("hub.mode"), query.Get("hub.topic"), lease) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(query.Get("hub.challenge")))
+}
+
+// Receive - get content pushed by hub
+func (ctrl *WebSubController) Receive(w http.ResponseWriter, r *http.Request) {
+	feedID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPushSize))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// hub must get success status even for invalid content
+	if err := ctrl.service.Receive(feedID, r.Header.Get("X-Hub-Signature"), data); err != nil {
+		log.Printf("WebSub content for feed %d is ignored: %s", feedID, err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"newshub-server/middleware"
+	"newshub-server/models"
+	"newshub-server/services"
+
+	"github.com/gorilla/mux"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const testTopic = "https://example.com/feed.xml"
+
+var (
+	testConfig *models.Config
+	testDb     *gorm.DB
+)
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "newshub")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// hub and callback are local servers
+	testConfig = &models.Config{
+		Driver:              "sqlite3",
+		ConnectionString:    filepath.Join(dir, "test.db"),
+		PageSize:            20,
+		UpdateWorkers:       1,
+		WebSubLease:         864000,
+		FetchTimeout:        5,
+		FetchConnectTimeout: 5,
+		FetchMaxRedirects:   5,
+		FetchAllowlist:      []string{"127.0.0.1"},
+	}
+	services.Setup(testConfig)
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupWebSub - start callback server with WebSub routes and authentication middleware
+func setupWebSub(t *testing.T) *WebSubController {
+	ctrl := NewWebSubCtrl(testConfig)
+
+	// the same database is checked by tests
+	if testDb == nil {
+		var err error
+		if testDb, err = gorm.Open(sqlite.Open(testConfig.ConnectionString), &gorm.Config{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	amw := middleware.AuthenticationMiddleware{}
+	amw.Populate(testConfig)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/websub/{id}", ctrl.Verify).Methods(http.MethodGet)
+	router.HandleFunc("/websub/{id}", ctrl.Receive).Methods(http.MethodPost)
+	router.Use(amw.Middleware)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	testConfig.PublicUrl = server.URL + "/"
+
+	return ctrl
+}
+
+// testHub - local WebSub hub, subscriptions are verified before response when autoVerify is set
+type testHub struct {
+	server     *httptest.Server
+	mutex      sync.Mutex
+	requests   []url.Values
+	autoVerify bool
+}
+
+func newTestHub(t *testing.T, autoVerify bool) *testHub {
+	hub := &testHub{autoVerify: autoVerify}
+	hub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		hub.mutex.Lock()
+		hub.requests = append(hub.requests, r.PostForm)
+		hub.mutex.Unlock()
+
+		if hub.autoVerify {
+			if status, _ := verify(t, r.PostForm.Get("hub.callback"), r.PostForm.Get("hub.mode"), r.PostForm.Get("hub.topic")); status != http.StatusOK {
+				t.Errorf("verification status %d", status)
+			}
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(hub.server.Close)
+
+	return hub
+}
+
+// lastRequest - form of last subscription request
+func (hub *testHub) lastRequest(t *testing.T) url.Values {
+	t.Helper()
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if len(hub.requests) == 0 {
+		t.Fatal("hub got no requests")
+	}
+
+	return hub.requests[len(hub.requests)-1]
+}
+
+// verify - send verification request to callback, status and content type of response are returned
+func verify(t *testing.T, callback, mode, topic string) (int, string) {
+	// challenge looks like html, so content type is not detected as plain text when it is not set
+	challenge := "<html>" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	query := url.Values{
+		"hub.mode":          {mode},
+		"hub.topic":         {topic},
+		"hub.challenge":     {challenge},
+		"hub.lease_seconds": {"3600"},
+	}
+
+	response, err := http.Get(callback + "?" + query.Encode())
+	if err != nil {
+		t.Errorf("verification request error: %s", err)
+		return 0, ""
+	}
+
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+
+	if response.StatusCode == http.StatusOK && string(body) != challenge {
+		t.Errorf("challenge %q is not echoed, body %q", challenge, body)
+	}
+
+	return response.StatusCode, response.Header.Get("Content-Type")
+}
+
+// publish - push content to callback signed with secret
+func publish(t *testing.T, callback, secret string, data []byte) int {
+	request, err := http.NewRequest(http.MethodPost, callback, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+
+	request.Header.Set("Content-Type", "application/rss+xml")
+	request.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("publish error: %s", err)
+	}
+
+	response.Body.Close()
+
+	return response.StatusCode
+}
+
+func createHubFeed(t *testing.T, hub *testHub, secret string) models.Feeds {
+	feed := models.Feeds{
+		UserId:     1,
+		Name:       "feed",
+		Url:        testTopic,
+		HubUrl:     hub.server.URL,
+		HubTopic:   testTopic,
+		HubSecret:  secret,
+		HubExpires: time.Now().Add(time.Hour).Unix(),
+	}
+	if err := testDb.Create(&feed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return feed
+}
+
+func getFeed(id int64) models.Feeds {
+	feed := models.Feeds{}
+	testDb.Where(&models.Feeds{Id: id}).Find(&feed)
+
+	return feed
+}
+
+func feedCallback(feed models.Feeds) string {
+	return strings.TrimRight(testConfig.PublicUrl, "/") + "/websub/" + strconv.FormatInt(feed.Id, 10)
+}
+
+func testRss(guids ...string) []byte {
+	var items strings.Builder
+	for _, guid := range guids {
+		fmt.Fprintf(&items, "<item><guid>%s</guid><title>item %s</title><link>https://example.com/%s</link></item>", guid, guid, guid)
+	}
+
+	return []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>feed</title><link>https://example.com/</link>` +
+		items.String() + `</channel></rss>`)
+}
+
+func articleGuids(feedID int64) string {
+	var guids []string
+	testDb.Model(&models.Articles{}).Where(&models.Articles{FeedId: feedID}).Order("Guid").Pluck("Guid", &guids)
+
+	return strings.Join(guids, ",")
+}
+
+func TestWebSubSubscribe(t *testing.T) {
+	ctrl := setupWebSub(t)
+	hub := newTestHub(t, true)
+	feed := createHubFeed(t, hub, "")
+	callback := feedCallback(feed)
+
+	if err := ctrl.service.Subscribe(feed); err != nil {
+		t.Fatal(err)
+	}
+
+	form := hub.lastRequest(t)
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != testTopic || form.Get("hub.callback") != callback {
+		t.Fatalf("subscription request %v", form)
+	}
+
+	secret := form.Get("hub.secret")
+	saved := getFeed(feed.Id)
+	if saved.HubSecret != secret || saved.HubNewSecret != "" {
+		t.Errorf("secret of verified subscription is not saved")
+	}
+	if saved.HubExpires < time.Now().Add(50*time.Minute).Unix() {
+		t.Errorf("lease end %d is not saved", saved.HubExpires)
+	}
+
+	if status := publish(t, callback, secret, testRss("1", "2")); status != http.StatusAccepted {
+		t.Errorf("push status %d", status)
+	}
+	// hub gets success status for content with invalid signature too
+	if status := publish(t, callback, "other", testRss("3")); status != http.StatusAccepted {
+		t.Errorf("push status %d", status)
+	}
+	if guids := articleGuids(feed.Id); guids != "1,2" {
+		t.Errorf("saved articles %s, want 1,2", guids)
+	}
+}
+
+func TestWebSubRenewKeepsSecretUntilVerification(t *testing.T) {
+	ctrl := setupWebSub(t)
+	hub := newTestHub(t, false)
+	feed := createHubFeed(t, hub, "old")
+	callback := feedCallback(feed)
+
+	if err := ctrl.service.Subscribe(feed); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := hub.lastRequest(t).Get("hub.secret")
+	if secret == "" || secret == "old" {
+		t.Fatalf("new secret is not sent to hub")
+	}
+
+	saved := getFeed(feed.Id)
+	if saved.HubSecret != "old" || saved.HubNewSecret != secret {
+		t.Fatalf("secrets before verification are %q and %q", saved.HubSecret, saved.HubNewSecret)
+	}
+
+	// content is checked with previous secret until verification
+	publish(t, callback, "old", testRss("1"))
+	publish(t, callback, secret, testRss("2"))
+
+	// verification of other topic does not change secret
+	if status, _ := verify(t, callback, "subscribe", "https://example.com/other.xml"); status != http.StatusNotFound {
+		t.Errorf("verification of other topic status %d", status)
+	}
+	if saved := getFeed(feed.Id); saved.HubSecret != "old" {
+		t.Errorf("secret is changed by failed verification")
+	}
+
+	status, contentType := verify(t, callback, "subscribe", testTopic)
+	if status != http.StatusOK || contentType != "text/plain; charset=utf-8" {
+		t.Fatalf("verification status %d and content type %q", status, contentType)
+	}
+
+	saved = getFeed(feed.Id)
+	if saved.HubSecret != secret || saved.HubNewSecret != "" {
+		t.Errorf("secrets after verification are %q and %q", saved.HubSecret, saved.HubNewSecret)
+	}
+
+	// verified request is not pending anymore
+	if status, _ := verify(t, callback, "subscribe", testTopic); status != http.StatusNotFound {
+		t.Errorf("second verification status %d", status)
+	}
+
+	publish(t, callback, "old", testRss("3"))
+	publish(t, callback, secret, testRss("4"))
+
+	if guids := articleGuids(feed.Id); guids != "1,4" {
+		t.Errorf("saved articles %s, want 1,4", guids)
+	}
+}
+
+func TestWebSubVerifyRequest(t *testing.T) {
+	setupWebSub(t)
+	hub := newTestHub(t, false)
+	feed := createHubFeed(t, hub, "secret")
+
+	tests := []struct {
+		name   string
+		path   string
+		mode   string
+		status int
+	}{
+		{"subscription is not requested", "/websub/" + strconv.FormatInt(feed.Id, 10), "subscribe", http.StatusNotFound},
+		{"unknown mode", "/websub/" + strconv.FormatInt(feed.Id, 10), "refresh", http.StatusNotFound},
+		{"invalid feed id", "/websub/feed", "subscribe", http.StatusBadRequest},
+		{"unsubscribe deleted feed", "/websub/" + strconv.FormatInt(feed.Id+1000, 10), "unsubscribe", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			callback := strings.TrimRight(testConfig.PublicUrl, "/") + test.path
+
+			if status, _ := verify(t, callback, test.mode, testTopic); status != test.status {
+				t.Errorf("status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	if saved := getFeed(feed.Id); saved.HubExpires != feed.HubExpires || saved.HubSecret != "secret" {
+		t.Errorf("subscription is changed by rejected requests")
+	}
+}
@@ -15,12 +15,14 @@ import (
 )
 
 var conf *models.Config
+var resanitize bool
 
 const defaultConfigPath = "./cfg.json"
 
 func init() {
 	// read config file
 	pathPtr := flag.String("config", defaultConfigPath, "Path for configuration file")
+	flag.BoolVar(&resanitize, "resanitize", false, "Sanitize bodies of stored articles and exit")
 	flag.Parse()
 
 	conf = models.NewConfig(*pathPtr)
@@ -31,23 +33,66 @@ func createRouter() http.Handler {
 	userCtrl := controllers.NewUserCtrl(conf)
 	vkCtrl := controllers.NewVkCtrl(conf)
 	twitterCtrl := controllers.NewTwitterCtrl(conf)
+	webSubCtrl := controllers.NewWebSubCtrl(conf)
+	retentionCtrl := controllers.NewRetentionCtrl(conf)
+	categoryCtrl := controllers.NewCategoryCtrl(conf)
+	tagCtrl := controllers.NewTagCtrl(conf)
+	savedSearchCtrl := controllers.NewSavedSearchCtrl(conf)
+	filterCtrl := controllers.NewFilterCtrl(conf)
 	router := mux.NewRouter()
 	router.StrictSlash(true)
 
+	// categories
+	router.HandleFunc("/rss/categories", categoryCtrl.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/rss/categories", categoryCtrl.Create).Methods(http.MethodPost)
+	router.HandleFunc("/rss/categories/{id}", categoryCtrl.Update).Methods(http.MethodPut)
+	router.HandleFunc("/rss/categories/{id}", categoryCtrl.Delete).Methods(http.MethodDelete)
+	router.HandleFunc("/rss/categories/{id}/articles", categoryCtrl.GetArticles).Methods(http.MethodGet)
+	router.HandleFunc("/rss/categories/{id}/read", categoryCtrl.MarkRead).Methods(http.MethodPut)
+
+	// tags
+	router.HandleFunc("/rss/tags", tagCtrl.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/rss/tags/{id}", tagCtrl.Delete).Methods(http.MethodDelete)
+	router.HandleFunc("/rss/tags/{id}/articles", tagCtrl.GetArticles).Methods(http.MethodGet)
+	router.HandleFunc("/rss/articles/{id}/tags", tagCtrl.AddTag).Methods(http.MethodPost)
+	router.HandleFunc("/rss/articles/{id}/tags/{tag_id}", tagCtrl.RemoveTag).Methods(http.MethodDelete)
+
+	// saved searches
+	router.HandleFunc("/rss/searches", savedSearchCtrl.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/rss/searches", savedSearchCtrl.Create).Methods(http.MethodPost)
+	router.HandleFunc("/rss/searches/{id}", savedSearchCtrl.Update).Methods(http.MethodPut)
+	router.HandleFunc("/rss/searches/{id}", savedSearchCtrl.Delete).Methods(http.MethodDelete)
+
 	// rss
 	router.HandleFunc("/rss", rssCtrl.GetAll).Methods(http.MethodGet)
 	router.HandleFunc("/rss", rssCtrl.AddFeed).Methods(http.MethodPost)
+	router.HandleFunc("/rss/preview", rssCtrl.Preview).Methods(http.MethodPost)
 	router.HandleFunc("/rss/{id}", rssCtrl.Delete).Methods(http.MethodDelete)
 	router.HandleFunc("/rss/{id}", rssCtrl.SetNewFeedName).Methods(http.MethodPut)
+	router.HandleFunc("/rss/{id}/status", rssCtrl.GetStatus).Methods(http.MethodGet)
 	router.HandleFunc("/rss/search", rssCtrl.Search).Methods(http.MethodGet)
 	router.HandleFunc("/rss/opml", rssCtrl.UploadOpml).Methods(http.MethodPost)
 	router.HandleFunc("/rss/opml", rssCtrl.CreateOpml).Methods(http.MethodGet)
+	router.HandleFunc("/rss/opml/jobs/{id}", rssCtrl.GetImportJob).Methods(http.MethodGet)
+	router.HandleFunc("/rss/opml/jobs/{id}", rssCtrl.CancelImportJob).Methods(http.MethodDelete)
 
 	// articles
 	router.HandleFunc("/rss/{feed_id}/articles", rssCtrl.GetArticles).Methods(http.MethodGet)
 	router.HandleFunc("/rss/{feed_id}/articles/{id}", rssCtrl.GetArticle).Methods(http.MethodGet)
 	router.HandleFunc("/rss/{feed_id}/articles/{id}", rssCtrl.UpdateArticle).Methods(http.MethodPut)
 	router.HandleFunc("/rss/articles/bookmarks", rssCtrl.GetBookmarks)
+	router.HandleFunc("/rss/articles/mark-read", rssCtrl.MarkRead).Methods(http.MethodPost)
+
+	// podcasts
+	router.HandleFunc("/rss/enclosures/{id}/position", rssCtrl.GetPlayback).Methods(http.MethodGet)
+	router.HandleFunc("/rss/enclosures/{id}/position", rssCtrl.SavePlayback).Methods(http.MethodPut)
+
+	// filters
+	router.HandleFunc("/filters", filterCtrl.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/filters", filterCtrl.Create).Methods(http.MethodPost)
+	router.HandleFunc("/filters/test", filterCtrl.Test).Methods(http.MethodPost)
+	router.HandleFunc("/filters/{id}", filterCtrl.Update).Methods(http.MethodPut)
+	router.HandleFunc("/filters/{id}", filterCtrl.Delete).Methods(http.MethodDelete)
 
 	// user
 	router.HandleFunc("/auth", userCtrl.Auth).Methods(http.MethodPost)
@@ -58,15 +103,24 @@ func createRouter() http.Handler {
 
 	// vk
 	router.HandleFunc("/vk", vkCtrl.GetPageData)
+	router.HandleFunc("/vk/news", vkCtrl.AddNews).Methods(http.MethodPost)
 	router.HandleFunc("/vk/news", vkCtrl.GetNews)
 	router.HandleFunc("/vk/search", vkCtrl.Search)
 
 	// twitter
 	router.HandleFunc("/twitter", twitterCtrl.GetPageData).Methods(http.MethodGet)
 	router.HandleFunc("/twitter/news", twitterCtrl.GetNews).Methods(http.MethodGet)
+	router.HandleFunc("/twitter/news", twitterCtrl.AddNews).Methods(http.MethodPost)
 	router.HandleFunc("/twitter/sources", twitterCtrl.GetSources).Methods(http.MethodGet)
 	router.HandleFunc("/twitter/search", twitterCtrl.Search).Methods(http.MethodGet)
 
+	// websub
+	router.HandleFunc("/websub/{id}", webSubCtrl.Verify).Methods(http.MethodGet)
+	router.HandleFunc("/websub/{id}", webSubCtrl.Receive).Methods(http.MethodPost)
+
+	// retention
+	router.HandleFunc("/retention", retentionCtrl.Report).Methods(http.MethodGet)
+
 	// middleware
 	amw := middleware.AuthenticationMiddleware{}
 	amw.Populate(conf)
@@ -81,7 +135,24 @@ func main() {
 	services.Setup(conf)
 	controllers.Config = conf
 
+	if resanitize {
+		changed, err := services.NewRssService(conf).Resanitize()
+		if err != nil {
+			log.Fatalln("sanitize articles error:", err)
+		}
+
+		log.Println("sanitized articles:", changed)
+		return
+	}
+
 	router := createRouter()
+	updateService := services.NewUpdateService(conf)
+	updateService.Start()
+	webSubService := services.NewWebSubService(conf)
+	webSubService.Start()
+	retentionService := services.NewRetentionService(conf)
+	retentionService.Start()
+
 	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
 	originsOk := handlers.AllowedOrigins([]string{"*"})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "DELETE"})
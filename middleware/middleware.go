@@ -17,8 +17,9 @@ const authHeader = "Authorization"
 const bearer = "Bearer "
 
 type AuthenticationMiddleware struct {
-	allowRoutes map[string]bool // todo: config
-	config      *models.Config
+	allowRoutes   map[string]bool // todo: config
+	allowPrefixes []string
+	config        *models.Config
 }
 
 // Initialize it somewhere
@@ -30,6 +31,8 @@ func (amw *AuthenticationMiddleware) Populate(cfg *models.Config) {
 		"/registration":  true,
 		"/users/refresh": true,
 	}
+	// WebSub hubs call callbacks without token
+	amw.allowPrefixes = []string{"/websub/"}
 }
 
 // Middleware function, which will be called for each request
@@ -41,6 +44,12 @@ func (amw *AuthenticationMiddleware) Middleware(next http.Handler) http.Handler
 			next.ServeHTTP(w, r)
 			return
 		}
+		for _, prefix := range amw.allowPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
 		if err := amw.jwtValidate(r); err != nil {
 			log.Println("jwt validation error:", err)
 			http.Error(w, "Forbidden", http.StatusForbidden)
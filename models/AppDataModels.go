@@ -1,9 +1,25 @@
 package models
 
+// Feed - feed with count of unread articles, saved search is set for virtual feed of search
 type Feed struct {
 	Feed          Feeds
 	ArticlesCount int
 	ExistUnread   bool
+	SavedSearch   *SavedSearches
+}
+
+// Category - category with count of feeds and unread articles
+type Category struct {
+	Category      Categories
+	FeedsCount    int64
+	ArticlesCount int64
+	ExistUnread   bool
+}
+
+// Tag - tag with count of articles
+type Tag struct {
+	Tag           Tags
+	ArticlesCount int64
 }
 
 type ArticlesJSON struct {
@@ -11,6 +27,142 @@ type ArticlesJSON struct {
 	Count    int64
 }
 
+// MarkReadResult - count of articles marked as read
+type MarkReadResult struct {
+	Count int64
+}
+
+// AddNewsResult - count of saved vk posts or tweets, discarded items are not counted
+type AddNewsResult struct {
+	Count int
+}
+
+// FilterTestResult - items matched by filter rule in dry run
+type FilterTestResult struct {
+	Checked int
+	Matched int
+	Items   []FilterTestItem
+}
+
+// FilterTestItem - item matched by filter rule, source is "rss", "vk" or "twitter"
+type FilterTestItem struct {
+	Source string
+	Id     int64
+	Title  string
+	Body   string
+	Link   string
+	Author string
+	Feed   string
+}
+
+// FeedStatus - feed update health
+type FeedStatus struct {
+	FeedId      int64
+	LastFetch   int64
+	LastSuccess int64
+	LastError   string
+	HttpStatus  int
+	FailCount   int
+	IsPaused    bool
+}
+
+// RetentionReport - items removed by retention policy, nothing is removed in dry run
+type RetentionReport struct {
+	DryRun      bool
+	Articles    int64
+	VkNews      int64
+	TwitterNews int64
+	Feeds       []RetentionFeedReport
+}
+
+// RetentionFeedReport - articles of feed removed by retention policy with applied limits
+type RetentionFeedReport struct {
+	FeedId   int64
+	Name     string
+	Days     int
+	Count    int
+	Articles int64
+}
+
+// ParsedFeed - feed in any supported format, normalized by parser
+type ParsedFeed struct {
+	Format      string
+	Title       string
+	Link        string
+	Description string
+	TTL         int // update interval in minutes recommended by publisher
+	Hub         string
+	Self        string
+	Items       []ParsedItem
+}
+
+// ParsedItem - normalized feed item
+type ParsedItem struct {
+	Guid       string
+	Title      string
+	Link       string
+	Body       string
+	Author     string
+	Date       string
+	Enclosures []ParsedEnclosure
+}
+
+// ParsedEnclosure - media file of feed item, duration in seconds
+type ParsedEnclosure struct {
+	Url      string
+	Type     string
+	Length   int64
+	Duration int
+}
+
+// FeedCandidate - feed found in link tag of web page, type is MIME type of link
+type FeedCandidate struct {
+	Url   string
+	Title string
+	Type  string
+}
+
+// FeedPreview - feed content shown before subscription, candidates are set when page has several feeds
+type FeedPreview struct {
+	Url         string
+	Format      string
+	Title       string
+	Description string
+	Link        string
+	ItemCount   int
+	Items       []Articles
+	Candidates  []FeedCandidate
+}
+
+// OPMLImportReport - result of OPML import
+type OPMLImportReport struct {
+	Added   int
+	Skipped int
+	Failed  int
+	Feeds   []OPMLImportFeed
+}
+
+// OPMLImportFeed - import result of one feed, status is "added", "skipped" or "failed"
+type OPMLImportFeed struct {
+	Url      string
+	Title    string
+	Category string
+	Status   string
+	Error    string
+}
+
+// OPMLImportJob - state of background OPML import, status is "running", "done" or "cancelled"
+type OPMLImportJob struct {
+	Id        string
+	UserId    int64 `json:"-"`
+	Status    string
+	Total     int
+	Processed int
+	Started   int64
+	Finished  int64
+	OPMLImportReport
+}
+
 type AppSettings struct {
 	UnreadOnly    bool
 	MarkSameRead  bool
@@ -2,26 +2,64 @@ package models
 
 // Rss - structure for DB
 type Feeds struct {
-	Id       int64      `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
-	Name     string     `gorm:"column:Name"`
-	Url      string     `gorm:"column:Url"`
-	UserId   int64      `gorm:"column:UserId"`
-	Articles []Articles `gorm:"ForeignKey:FeedId"`
+	Id             int64      `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	Name           string     `gorm:"column:Name"`
+	Url            string     `gorm:"column:Url"`
+	SiteUrl        string     `gorm:"column:SiteUrl"`
+	UserId         int64      `gorm:"column:UserId"`
+	CategoryId     int64      `gorm:"column:CategoryId;index"`
+	ETag           string     `gorm:"column:ETag"`
+	LastModified   string     `gorm:"column:LastModified"`
+	LastFetch      int64      `gorm:"column:LastFetch"`
+	LastSuccess    int64      `gorm:"column:LastSuccess"`
+	LastError      string     `gorm:"column:LastError"`
+	HttpStatus     int        `gorm:"column:HttpStatus"`
+	FailCount      int        `gorm:"column:FailCount"`
+	IsPaused       bool       `gorm:"column:IsPaused"`
+	NextFetch      int64      `gorm:"column:NextFetch;index"`
+	Interval       int        `gorm:"column:Interval"`
+	UpdateMinutes  int        `gorm:"column:UpdateMinutes"`
+	HubUrl         string     `gorm:"column:HubUrl" json:"-"`
+	HubTopic       string     `gorm:"column:HubTopic" json:"-"`
+	HubSecret      string     `gorm:"column:HubSecret" json:"-"`
+	HubNewSecret   string     `gorm:"column:HubNewSecret" json:"-"`
+	HubRequested   int64      `gorm:"column:HubRequested" json:"-"`
+	HubExpires     int64      `gorm:"column:HubExpires"`
+	FullContent    bool       `gorm:"column:FullContent"`
+	RetentionDays  int        `gorm:"column:RetentionDays"`
+	RetentionCount int        `gorm:"column:RetentionCount"`
+	Articles       []Articles `gorm:"ForeignKey:FeedId"`
 }
 
 func (Feeds) TableName() string {
 	return "feeds"
 }
 
+// Categories - user folder of feeds
+type Categories struct {
+	Id     int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	UserId int64  `gorm:"column:UserId;index"`
+	Name   string `gorm:"column:Name"`
+}
+
+func (Categories) TableName() string {
+	return "categories"
+}
+
 type Articles struct {
-	Id         int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
-	FeedId     int64  `gorm:"column:FeedId;index"`
-	Title      string `gorm:"column:Title"`
-	Body       string `gorm:"column:Body;size:8192"`
-	Link       string `gorm:"column:Link"`
-	Date       int64  `gorm:"column:Date"`
-	IsRead     bool   `gorm:"column:IsRead"`
-	IsBookmark bool   `gorm:"column:IsBookmark"`
+	Id         int64        `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	FeedId     int64        `gorm:"column:FeedId;index;uniqueIndex:idx_articles_feed_guid"`
+	Guid       string       `gorm:"column:Guid;uniqueIndex:idx_articles_feed_guid"`
+	Title      string       `gorm:"column:Title"`
+	Body       string       `gorm:"column:Body;size:8192"`
+	FullBody   string       `gorm:"column:FullBody"`
+	Link       string       `gorm:"column:Link"`
+	Author     string       `gorm:"column:Author"`
+	Date       int64        `gorm:"column:Date"`
+	IsRead     bool         `gorm:"column:IsRead"`
+	IsBookmark bool         `gorm:"column:IsBookmark"`
+	Enclosures []Enclosures `gorm:"ForeignKey:ArticleId"`
+	Tags       []Tags       `gorm:"-"`
 	//Feed       Feeds
 }
 
@@ -29,6 +67,98 @@ func (Articles) TableName() string {
 	return "articles"
 }
 
+// Enclosures - media file of article, duration in seconds
+type Enclosures struct {
+	Id        int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	ArticleId int64  `gorm:"column:ArticleId;index"`
+	Url       string `gorm:"column:Url"`
+	Type      string `gorm:"column:Type"`
+	Length    int64  `gorm:"column:Length"`
+	Duration  int    `gorm:"column:Duration"`
+}
+
+func (Enclosures) TableName() string {
+	return "enclosures"
+}
+
+// Tags - user label of articles
+type Tags struct {
+	Id     int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	UserId int64  `gorm:"column:UserId;index;uniqueIndex:idx_tags_user_name"`
+	Name   string `gorm:"column:Name;uniqueIndex:idx_tags_user_name"`
+}
+
+func (Tags) TableName() string {
+	return "tags"
+}
+
+// ArticleTags - tag of article
+type ArticleTags struct {
+	Id        int64 `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	ArticleId int64 `gorm:"column:ArticleId;uniqueIndex:idx_articletags_article_tag"`
+	TagId     int64 `gorm:"column:TagId;index;uniqueIndex:idx_articletags_article_tag"`
+}
+
+func (ArticleTags) TableName() string {
+	return "articletags"
+}
+
+// PurgedArticles - guid of article removed by retention, such items are not added again while they are in feed
+type PurgedArticles struct {
+	Id     int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	FeedId int64  `gorm:"column:FeedId;uniqueIndex:idx_purgedarticles_feed_guid"`
+	Guid   string `gorm:"column:Guid;uniqueIndex:idx_purgedarticles_feed_guid"`
+	Date   int64  `gorm:"column:Date"`
+}
+
+func (PurgedArticles) TableName() string {
+	return "purgedarticles"
+}
+
+// SavedSearches - user search shown as virtual feed
+type SavedSearches struct {
+	Id         int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	UserId     int64  `gorm:"column:UserId;index"`
+	Name       string `gorm:"column:Name"`
+	Search     string `gorm:"column:Search"`
+	FeedId     int64  `gorm:"column:FeedId"`
+	IsBookmark bool   `gorm:"column:IsBookmark"`
+	UnreadOnly bool   `gorm:"column:UnreadOnly"`
+}
+
+func (SavedSearches) TableName() string {
+	return "savedsearches"
+}
+
+// FilterRules - user rule applied to new articles, vk posts and tweets
+type FilterRules struct {
+	Id        int64           `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	UserId    int64           `gorm:"column:UserId;index"`
+	Name      string          `gorm:"column:Name"`
+	Source    string          `gorm:"column:Source"`
+	Condition FilterCondition `gorm:"column:Condition;type:text"`
+	Action    string          `gorm:"column:Action"`
+	Tag       string          `gorm:"column:Tag"`
+	IsEnabled bool            `gorm:"column:IsEnabled"`
+}
+
+func (FilterRules) TableName() string {
+	return "filterrules"
+}
+
+// PlaybackPositions - user position of enclosure playback in seconds
+type PlaybackPositions struct {
+	Id          int64 `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
+	UserId      int64 `gorm:"column:UserId;uniqueIndex:idx_playback_user_enclosure"`
+	EnclosureId int64 `gorm:"column:EnclosureId;uniqueIndex:idx_playback_user_enclosure"`
+	Position    int   `gorm:"column:Position"`
+	Updated     int64 `gorm:"column:Updated"`
+}
+
+func (PlaybackPositions) TableName() string {
+	return "playbackpositions"
+}
+
 type Users struct {
 	Id                int64    `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
 	Name              string   `gorm:"column:Name"`
@@ -49,6 +179,7 @@ type Settings struct {
 	UserId               int64 `gorm:"column:UserId;index"`
 	UnreadOnly           bool  `gorm:"column:UnreadOnly"`
 	MarkSameRead         bool  `gorm:"column:MarkSameRead"`
+	MarkSameTitle        bool  `gorm:"column:MarkSameTitle"`
 	RssEnabled           bool  `gorm:"column:RssEnabled"`
 	VkNewsEnabled        bool  `gorm:"column:VkNewsEnabled"`
 	TwitterEnabled       bool  `gorm:"column:TwitterEnabled"`
@@ -58,6 +189,8 @@ type Settings struct {
 	ShowReadButton       bool  `gorm:"column:ShowReadButton"`
 	ShowLinkButton       bool  `gorm:"column:ShowLinkButton"`
 	ShowBookmarkButton   bool  `gorm:"column:ShowBookmarkButton"`
+	RetentionDays        int   `gorm:"column:RetentionDays"`
+	RetentionCount       int   `gorm:"column:RetentionCount"`
 }
 
 func (Settings) TableName() string {
@@ -68,9 +201,9 @@ func (Settings) TableName() string {
 ============================================================================= */
 type VkNews struct {
 	Id        int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
-	UserId    int64  `gorm:"column:UserId;index"`
-	GroupId   int64  `gorm:"column:GroupId;index"`
-	PostId    int64  `gorm:"column:PostId;index"`
+	UserId    int64  `gorm:"column:UserId;index;uniqueIndex:idx_vknews_user_post"`
+	GroupId   int64  `gorm:"column:GroupId;index;uniqueIndex:idx_vknews_user_post"`
+	PostId    int64  `gorm:"column:PostId;index;uniqueIndex:idx_vknews_user_post"`
 	Timestamp int64  `gorm:"column:Timestamp"`
 	Text      string `gorm:"column:Text"`
 	Image     string `gorm:"column:Image"`
@@ -98,9 +231,9 @@ func (VkGroup) TableName() string {
 ============================================================================= */
 type TwitterNews struct {
 	Id          int64  `gorm:"column:Id;primary_key;AUTO_INCREMENT"`
-	UserId      int64  `gorm:"column:UserId;index"`
+	UserId      int64  `gorm:"column:UserId;index;uniqueIndex:idx_twitternews_user_tweet"`
 	SourceId    int64  `gorm:"column:SourceId;index"`
-	TweetId     int64  `gorm:"column:TweetId"`
+	TweetId     int64  `gorm:"column:TweetId;uniqueIndex:idx_twitternews_user_tweet"`
 	Text        string `gorm:"column:Text"`
 	ExpandedUrl string `gorm:"column:ExpandedUrl"`
 	Image       string `gorm:"column:Image"`
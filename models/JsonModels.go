@@ -1,7 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 
@@ -10,16 +12,34 @@ import (
 
 // Config - app config, create from config file
 type Config struct {
-	Address          string `json:"address"`
-	Driver           string `json:"driver"`
-	ConnectionString string `json:"connection_string"`
-	DbHost           string `json:"db_host"`
-	DbName           string `json:"db_name"`
-	DbUser           string `json:"db_user"`
-	DbPassword       string `json:"db_password"`
-	DbPort           int    `json:"db_port"`
-	JwtSign          string `json:"jwt_sign"`
-	PageSize         int    `json:"page_size"`
+	Address             string   `json:"address"`
+	Driver              string   `json:"driver"`
+	ConnectionString    string   `json:"connection_string"`
+	DbHost              string   `json:"db_host"`
+	DbName              string   `json:"db_name"`
+	DbUser              string   `json:"db_user"`
+	DbPassword          string   `json:"db_password"`
+	DbPort              int      `json:"db_port"`
+	JwtSign             string   `json:"jwt_sign"`
+	PageSize            int      `json:"page_size"`
+	UpdateMinutes       int      `json:"update_minutes"`
+	UpdateWorkers       int      `json:"update_workers"`
+	MaxFeedFailures     int      `json:"max_feed_failures"`
+	MinUpdateMinutes    int      `json:"min_update_minutes"`
+	MaxUpdateMinutes    int      `json:"max_update_minutes"`
+	PublicUrl           string   `json:"public_url"`
+	WebSubLease         int      `json:"websub_lease_seconds"`
+	RetentionDays       int      `json:"retention_days"`
+	RetentionCount      int      `json:"retention_count"`
+	FetchConnectTimeout int      `json:"fetch_connect_timeout_seconds"`
+	FetchTimeout        int      `json:"fetch_timeout_seconds"`
+	FetchMaxBytes       int64    `json:"fetch_max_bytes"`
+	FetchMaxRedirects   int      `json:"fetch_max_redirects"`
+	FetchAllowlist      []string `json:"fetch_allowlist"`
+	FetchProxy          string   `json:"fetch_proxy"`
+	UserAgent           string   `json:"user_agent"`
+	HostConcurrency     int      `json:"fetch_host_concurrency"`
+	HostInterval        int      `json:"fetch_host_interval_ms"`
 }
 
 // NewConfig return new config struct pointer
@@ -41,6 +61,18 @@ func NewConfig(path string) *Config {
 
 	// set default values
 	cfg.PageSize = 20
+	cfg.UpdateMinutes = 30
+	cfg.UpdateWorkers = 5
+	cfg.MaxFeedFailures = 10
+	cfg.MinUpdateMinutes = 15
+	cfg.MaxUpdateMinutes = 1440
+	cfg.WebSubLease = 864000
+	cfg.FetchConnectTimeout = 10
+	cfg.FetchTimeout = 60
+	cfg.FetchMaxBytes = 20 << 20
+	cfg.FetchMaxRedirects = 5
+	cfg.HostConcurrency = 2
+	cfg.HostInterval = 1000
 
 	if err := json.Unmarshal(jsonBytes, cfg); err != nil {
 		panic(err.Error())
@@ -58,6 +90,7 @@ type SettingsData struct {
 	TwitterEnabled       bool   `json:"TwitterEnabled"`
 	TwitterSimpleVersion bool   `json:"TwitterSimpleVersion"`
 	MarkSameRead         bool   `json:"MarkSameRead"`
+	MarkSameTitle        bool   `json:"MarkSameTitle"`
 	UnreadOnly           bool   `json:"UnreadOnly"`
 	RssEnabled           bool   `json:"RssEnabled"`
 	ShowPreviewButton    bool   `json:"ShowPreviewButton"`
@@ -65,6 +98,8 @@ type SettingsData struct {
 	ShowReadButton       bool   `json:"ShowReadButton"`
 	ShowLinkButton       bool   `json:"ShowLinkButton"`
 	ShowBookmarkButton   bool   `json:"ShowBookmarkButton"`
+	RetentionDays        int    `json:"RetentionDays"`
+	RetentionCount       int    `json:"RetentionCount"`
 }
 
 type VkData struct {
@@ -106,7 +141,155 @@ type ArticlesUpdateData struct {
 }
 
 type FeedUpdateData struct {
-	FeedId    int64  `json:"feed_id"`
+	FeedId        int64  `json:"feed_id"`
+	Name          string `json:"name"`
+	IsReadAll     bool   `json:"is_read_all"`
+	Enable        bool   `json:"enable"`
+	UpdateMinutes int    `json:"update_minutes"` // 0 - keep current, -1 - adaptive interval
+	FullContent   *bool  `json:"full_content"`
+	// retention limits: 0 - use user settings, -1 - keep all articles
+	RetentionDays  *int `json:"retention_days"`
+	RetentionCount *int `json:"retention_count"`
+	// 0 - remove feed from category
+	CategoryId *int64 `json:"category_id"`
+}
+
+type TagData struct {
+	Name string `json:"name"`
+}
+
+type SavedSearchData struct {
+	Name       string `json:"name"`
+	Search     string `json:"search"`
+	FeedId     int64  `json:"feed_id"` // 0 - all feeds
+	IsBookmark bool   `json:"is_bookmark"`
+	UnreadOnly bool   `json:"unread_only"`
+}
+
+type MarkReadData struct {
+	Scope      string `json:"scope"` // all, feed, category or search
+	FeedId     int64  `json:"feed_id"`
+	CategoryId int64  `json:"category_id"`
+	Search     string `json:"search"`
+	OlderThan  int64  `json:"older_than"` // unix time, 0 - articles of any date
+}
+
+type CategoryUpdateData struct {
 	Name      string `json:"name"`
 	IsReadAll bool   `json:"is_read_all"`
 }
+
+// JSONFeed - struct for JSON Feed 1.0/1.1
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Hubs        []JSONFeedHub    `json:"hubs"`
+	Author      *JSONFeedAuthor  `json:"author"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+// JSONFeedHub - WebSub hub of JSON Feed
+type JSONFeedHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedItem - item in JSON Feed
+type JSONFeedItem struct {
+	Id            JSONFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *JSONFeedAuthor      `json:"author"`  // JSON Feed 1.0
+	Authors       []JSONFeedAuthor     `json:"authors"` // JSON Feed 1.1
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+// JSONFeedID - id of JSON Feed item, string by specification, but some feeds use numbers
+type JSONFeedID string
+
+// UnmarshalJSON - accept id as string or number
+func (id *JSONFeedID) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*id = JSONFeedID(text)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+
+	*id = JSONFeedID(number.String())
+
+	return nil
+}
+
+// JSONFeedAuthor - author of JSON Feed or item
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedAttachment - media file of JSON Feed item
+type JSONFeedAttachment struct {
+	URL      string  `json:"url"`
+	MimeType string  `json:"mime_type"`
+	Size     int64   `json:"size_in_bytes"`
+	Duration float64 `json:"duration_in_seconds"`
+}
+
+type PlaybackUpdateData struct {
+	EnclosureId int64 `json:"enclosure_id"`
+	Position    int   `json:"position"`
+}
+
+type FilterRuleData struct {
+	Name      string          `json:"name"`
+	Source    string          `json:"source"` // rss, vk or twitter, empty - all sources
+	Condition FilterCondition `json:"condition"`
+	Action    string          `json:"action"` // read, bookmark, tag or discard
+	Tag       string          `json:"tag"`
+	IsEnabled bool            `json:"is_enabled"`
+}
+
+// FilterCondition - condition of filter rule. Operations "contains" and "regex" search pattern in field
+// of item: title, body, link, author or feed, operations "and", "or" and "not" combine nested conditions
+type FilterCondition struct {
+	Op         string            `json:"op"`
+	Field      string            `json:"field,omitempty"`
+	Pattern    string            `json:"pattern,omitempty"`
+	Conditions []FilterCondition `json:"conditions,omitempty"`
+}
+
+// Value - condition is saved in DB as JSON
+func (condition FilterCondition) Value() (driver.Value, error) {
+	data, err := json.Marshal(condition)
+
+	return string(data), err
+}
+
+// Scan - read condition from JSON saved in DB
+func (condition *FilterCondition) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, condition)
+	case string:
+		return json.Unmarshal([]byte(data), condition)
+	case nil:
+		*condition = FilterCondition{}
+		return nil
+	}
+
+	return errors.New("filter condition is not JSON")
+}
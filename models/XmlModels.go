@@ -4,38 +4,155 @@ import "encoding/xml"
 
 // XMLFeed - struct for RSS XML
 type XMLFeed struct {
-	XMLName     xml.Name     `xml:"rss"`
-	Version     string       `xml:"version,attr"`
-	RssName     string       `xml:"channel>title"`
-	RssURL      string       `xml:"channel>link"`
-	Description string       `xml:"channel>description"`
-	Articles    []XMLArticle `xml:"channel>item"`
+	XMLName         xml.Name     `xml:"rss"`
+	Version         string       `xml:"version,attr"`
+	RssName         string       `xml:"channel>title"`
+	Links           []XMLLink    `xml:"channel>link"`
+	Description     string       `xml:"channel>description"`
+	TTL             int          `xml:"channel>ttl"`
+	UpdatePeriod    string       `xml:"channel>updatePeriod"`
+	UpdateFrequency int          `xml:"channel>updateFrequency"`
+	Articles        []XMLArticle `xml:"channel>item"`
+}
+
+// XMLLink - link of RSS channel, atom:link elements have href and rel attributes
+type XMLLink struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Text    string `xml:",chardata"`
 }
 
 // XMLArticle - article in RSS XML
 type XMLArticle struct {
+	Guid        string            `xml:"guid"`
+	Title       string            `xml:"title"`
+	Link        string            `xml:"link"`
+	Description string            `xml:"description"`
+	Date        string            `xml:"pubDate"`
+	Author      string            `xml:"author"`
+	Creator     string            `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Enclosures  []XMLEnclosure    `xml:"enclosure"`
+	Media       []XMLMediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups []XMLMediaGroup   `xml:"http://search.yahoo.com/mrss/ group"`
+	Duration    string            `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	IsRead      bool
+}
+
+// XMLEnclosure - media file of RSS item, numbers are strings because feeds often have invalid values
+type XMLEnclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// XMLMediaContent - media:content element of Media RSS
+type XMLMediaContent struct {
+	Url      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// XMLMediaGroup - media:group element with several versions of media
+type XMLMediaGroup struct {
+	Contents []XMLMediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+/*==============================================================================
+	RSS 1.0 (RDF) models
+==============================================================================*/
+
+// RDFFeed - struct for RSS 1.0 XML
+type RDFFeed struct {
+	XMLName         xml.Name     `xml:"RDF"`
+	RssName         string       `xml:"channel>title"`
+	RssURL          string       `xml:"channel>link"`
+	Description     string       `xml:"channel>description"`
+	UpdatePeriod    string       `xml:"channel>updatePeriod"`
+	UpdateFrequency int          `xml:"channel>updateFrequency"`
+	Articles        []RDFArticle `xml:"item"`
+}
+
+// RDFArticle - article in RSS 1.0 XML
+type RDFArticle struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
-	Date        string `xml:"pubDate"`
-	IsRead      bool
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+/*==============================================================================
+	Atom models
+==============================================================================*/
+
+// AtomFeed - struct for Atom 1.0 XML
+type AtomFeed struct {
+	XMLName  xml.Name     `xml:"feed"`
+	Base     string       `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle"`
+	Links    []AtomLink   `xml:"link"`
+	Authors  []AtomPerson `xml:"author"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+// AtomEntry - entry in Atom XML
+type AtomEntry struct {
+	Base      string       `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Id        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Links     []AtomLink   `xml:"link"`
+	Authors   []AtomPerson `xml:"author"`
+	Content   AtomText     `xml:"content"`
+	Summary   AtomText     `xml:"summary"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+}
+
+// AtomPerson - author of feed or entry
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// AtomLink - link element of feed or entry, relative href is resolved against xml:base
+type AtomLink struct {
+	Base   string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// AtomText - text construct, xhtml content is kept as inner XML
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
 }
 
 /*==============================================================================
 	OPML models
 ==============================================================================*/
 
-// OPML - struct for OPML file
+// OPML - struct for OPML 2.0 file
 type OPML struct {
-	XMLName  xml.Name      `xml:"opml"`
-	Version  float32       `xml:"version,attr"`
-	HeadText string        `xml:"head>text"`
-	Outlines []OPMLOutline `xml:"body>outline"`
+	XMLName     xml.Name      `xml:"opml"`
+	Version     string        `xml:"version,attr"`
+	Title       string        `xml:"head>title"`
+	DateCreated string        `xml:"head>dateCreated,omitempty"`
+	Outlines    []OPMLOutline `xml:"body>outline"`
 }
 
-// OPMLOutline - RSS description in OPML file
+// OPMLOutline - RSS description in OPML file, outline without url is a category of nested outlines
 type OPMLOutline struct {
-	Title string `xml:"title,attr"`
-	URL   string `xml:"xmlUrl,attr"`
-	Text  string `xml:"text,attr"`
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
 }
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+)
+
+// CategoryService - user categories of feeds
+type CategoryService struct {
+	db     *gorm.DB
+	config *models.Config
+}
+
+func NewCategoryService(config *models.Config) *CategoryService {
+	return &CategoryService{
+		db:     getDb(),
+		config: config,
+	}
+}
+
+func (service *CategoryService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// GetAll - get categories of user with count of feeds and unread articles
+func (service *CategoryService) GetAll(userID int64) []models.Category {
+	var categories []models.Categories
+	var feedCounts []struct {
+		CategoryId int64
+		Count      int64
+	}
+	var unreadCounts []struct {
+		CategoryId int64
+		Count      int64
+	}
+
+	service.db.Where(&models.Categories{UserId: userID}).Order("Name").Find(&categories)
+	service.db.Model(&models.Feeds{}).
+		Select("CategoryId, count(*) as Count").
+		Where(&models.Feeds{UserId: userID}).
+		Group("CategoryId").
+		Scan(&feedCounts)
+	service.db.Model(&models.Articles{}).
+		Joins("join feeds on articles.FeedId = feeds.Id").
+		Select("feeds.CategoryId as CategoryId, count(*) as Count").
+		Where("feeds.UserId = ?", userID).
+		Not(&models.Articles{IsRead: true}).
+		Group("feeds.CategoryId").
+		Scan(&unreadCounts)
+
+	result := make([]models.Category, len(categories))
+	for i, category := range categories {
+		result[i].Category = category
+
+		for _, count := range feedCounts {
+			if count.CategoryId == category.Id {
+				result[i].FeedsCount = count.Count
+			}
+		}
+		for _, count := range unreadCounts {
+			if count.CategoryId == category.Id {
+				result[i].ArticlesCount = count.Count
+				result[i].ExistUnread = count.Count > 0
+			}
+		}
+	}
+
+	return result
+}
+
+// Get - get category of user, Id is 0 when category is not found
+func (service *CategoryService) Get(id int64, userID int64) models.Categories {
+	category := models.Categories{}
+	service.db.Where(&models.Categories{Id: id, UserId: userID}).Find(&category)
+
+	return category
+}
+
+// Create - add new category
+func (service *CategoryService) Create(name string, userID int64) (models.Categories, error) {
+	category := models.Categories{UserId: userID, Name: strings.TrimSpace(name)}
+
+	if category.Name == "" {
+		return category, errors.New("category name is empty")
+	}
+
+	err := service.db.Create(&category).Error
+
+	return category, err
+}
+
+// Update - rename category or mark all its articles as read
+func (service *CategoryService) Update(id int64, data models.CategoryUpdateData, userID int64) *models.Categories {
+	category := service.Get(id, userID)
+
+	if category.Id == 0 {
+		return nil
+	}
+
+	if name := strings.TrimSpace(data.Name); name != "" {
+		category.Name = name
+		service.db.Save(&category)
+	}
+	if data.IsReadAll {
+		service.MarkRead(category.Id, userID)
+	}
+
+	return &category
+}
+
+// Delete - remove category, its feeds are kept without category
+func (service *CategoryService) Delete(id int64, userID int64) error {
+	category := service.Get(id, userID)
+
+	if category.Id == 0 {
+		return nil
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Feeds{}).
+			Where(&models.Feeds{UserId: userID, CategoryId: category.Id}).
+			UpdateColumn("CategoryId", 0).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&category).Error
+	})
+}
+
+// GetArticles - get articles of all feeds in category
+func (service *CategoryService) GetArticles(id int64, userID int64, page int) *models.ArticlesJSON {
+	var articles []models.Articles
+	var count int64
+	offset := service.config.PageSize * (page - 1)
+
+	query := service.db.Where("FeedId IN (?)", service.feedIds(id, userID)).
+		Select("Id, Title, IsBookmark, IsRead, Link, FeedId, Date").
+		Preload("Enclosures").
+		Limit(service.config.PageSize).
+		Offset(offset).
+		Order("Date desc, Id desc")
+	queryCount := service.db.Model(&models.Articles{}).Where("FeedId IN (?)", service.feedIds(id, userID))
+
+	var settings models.Settings
+	service.db.Where(models.Settings{UserId: userID}).Find(&settings)
+
+	if settings.UnreadOnly {
+		whereNotObject := models.Articles{IsRead: true}
+		query = query.Not(&whereNotObject)
+		queryCount = queryCount.Not(&whereNotObject)
+	}
+
+	query.Find(&articles)
+	queryCount.Count(&count)
+
+	attachTags(service.db, articles)
+
+	return &models.ArticlesJSON{Articles: articles, Count: count}
+}
+
+// MarkRead - mark all articles of category as read, count of changed articles is returned
+func (service *CategoryService) MarkRead(id int64, userID int64) int64 {
+	return service.db.Model(&models.Articles{}).
+		Where("FeedId IN (?)", service.feedIds(id, userID)).
+		Not(&models.Articles{IsRead: true}).
+		UpdateColumn("IsRead", true).
+		RowsAffected
+}
+
+// feedIds - subquery of feeds in category
+func (service *CategoryService) feedIds(id int64, userID int64) *gorm.DB {
+	return service.db.Model(&models.Feeds{}).
+		Select("Id").
+		Where(&models.Feeds{UserId: userID}).
+		Where("CategoryId = ? and CategoryId <> 0", id)
+}
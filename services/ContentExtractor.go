@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"newshub-server/models"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gorm.io/gorm"
+)
+
+// fullContentRetry - page of article is not downloaded again during this time after failed attempt
+const fullContentRetry = time.Hour
+
+var (
+	// fullContentAttempts - time of running or failed download by article id
+	fullContentAttempts      = make(map[int64]time.Time)
+	fullContentAttemptsMutex sync.Mutex
+)
+
+var (
+	positiveClass = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+	negativeClass = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|share|social|related|nav|menu|promo|sponsor|advert|banner|widget|popup|subscribe|header|breadcrumb|combx|masthead`)
+)
+
+// removedTags - elements without main content
+var removedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Svg:      true,
+}
+
+// fetchFullContent - download article page and extract its content
+func fetchFullContent(link string) (string, error) {
+	if link == "" {
+		return "", errors.New("article has no link")
+	}
+
+	result, err := fetchData(link, "", "")
+	if err != nil {
+		return "", err
+	}
+
+	return extractContent(result.Data)
+}
+
+// saveFullContentAsync - download content of articles one by one in background and save it sanitized,
+// article is skipped while its page is downloaded and for some time after failure
+func saveFullContentAsync(db *gorm.DB, articles ...models.Articles) {
+	started := make([]models.Articles, 0, len(articles))
+	for _, article := range articles {
+		if startFullContent(article.Id) {
+			started = append(started, article)
+		}
+	}
+
+	if len(started) == 0 {
+		return
+	}
+
+	go func() {
+		for _, article := range started {
+			saveFullContent(db, article)
+		}
+	}()
+}
+
+// saveFullContent - download page of article and save extracted content,
+// attempt is forgotten after success only
+func saveFullContent(db *gorm.DB, article models.Articles) {
+	content, err := fetchFullContent(article.Link)
+	if err != nil {
+		log.Printf("get full content of %s error: %s", article.Link, err)
+		return
+	}
+
+	content = sanitizeHTML(content, article.Link)
+
+	if err := db.Model(&models.Articles{Id: article.Id}).UpdateColumn("FullBody", content).Error; err != nil {
+		log.Println("save full content error:", err)
+		return
+	}
+
+	fullContentAttemptsMutex.Lock()
+	delete(fullContentAttempts, article.Id)
+	fullContentAttemptsMutex.Unlock()
+}
+
+// startFullContent - record attempt to download article page, false if article was tried recently
+func startFullContent(articleID int64) bool {
+	fullContentAttemptsMutex.Lock()
+	defer fullContentAttemptsMutex.Unlock()
+
+	expired := time.Now().Add(-fullContentRetry)
+
+	for id, started := range fullContentAttempts {
+		if started.Before(expired) {
+			delete(fullContentAttempts, id)
+		}
+	}
+
+	if _, ok := fullContentAttempts[articleID]; ok {
+		return false
+	}
+
+	fullContentAttempts[articleID] = time.Now()
+
+	return true
+}
+
+// extractContent - get main content of web page with readability-like scoring:
+// paragraphs give points to parents, the best scored element and its good siblings are returned
+func extractContent(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	cleanTree(doc)
+
+	scores := make(map[*html.Node]float64)
+
+	walkNodes(doc, func(node *html.Node) {
+		if node.Type != html.ElementNode || (node.DataAtom != atom.P && node.DataAtom != atom.Pre && node.DataAtom != atom.Td) {
+			return
+		}
+
+		text := nodeText(node)
+		if len(text) < 25 || node.Parent == nil {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		parent := node.Parent
+
+		if _, ok := scores[parent]; !ok {
+			scores[parent] = initialScore(parent)
+		}
+		scores[parent] += score
+
+		if grandParent := parent.Parent; grandParent != nil && grandParent.Type == html.ElementNode {
+			if _, ok := scores[grandParent]; !ok {
+				scores[grandParent] = initialScore(grandParent)
+			}
+			scores[grandParent] += score / 2
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+
+	for node, score := range scores {
+		score *= 1 - linkDensity(node)
+		scores[node] = score
+
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+
+	if best == nil {
+		return "", errors.New("content not found")
+	}
+
+	// siblings with good score or long paragraphs are a part of content too
+	threshold := math.Max(10, bestScore*0.2)
+	var buffer bytes.Buffer
+
+	for sibling := best.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		include := sibling == best || scores[sibling] >= threshold
+		if !include && sibling.DataAtom == atom.P {
+			text := nodeText(sibling)
+			include = len(text) > 80 && linkDensity(sibling) < 0.25
+		}
+		if !include {
+			continue
+		}
+		if err := html.Render(&buffer, sibling); err != nil {
+			return "", err
+		}
+	}
+
+	return buffer.String(), nil
+}
+
+// cleanTree - remove elements without content and hidden blocks
+func cleanTree(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.CommentNode || (child.Type == html.ElementNode && (removedTags[child.DataAtom] || isUnlikely(child))) {
+			node.RemoveChild(child)
+		} else {
+			cleanTree(child)
+		}
+
+		child = next
+	}
+}
+
+// isUnlikely - check class and id of element for non content names
+func isUnlikely(node *html.Node) bool {
+	if node.DataAtom == atom.Body || node.DataAtom == atom.Html || node.DataAtom == atom.Article || node.DataAtom == atom.Main {
+		return false
+	}
+
+	names := attr(node, "class") + " " + attr(node, "id")
+
+	return negativeClass.MatchString(names) && !positiveClass.MatchString(names)
+}
+
+func initialScore(node *html.Node) float64 {
+	score := 0.0
+
+	switch node.DataAtom {
+	case atom.Article, atom.Main:
+		score += 10
+	case atom.Div:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Ul, atom.Ol, atom.Dl, atom.Form, atom.Address:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+
+	for _, name := range []string{attr(node, "class"), attr(node, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeClass.MatchString(name) {
+			score -= 25
+		}
+		if positiveClass.MatchString(name) {
+			score += 25
+		}
+	}
+
+	return score
+}
+
+// linkDensity - part of element text inside links
+func linkDensity(node *html.Node) float64 {
+	textLength := len(nodeText(node))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	walkNodes(node, func(child *html.Node) {
+		if child.Type == html.ElementNode && child.DataAtom == atom.A {
+			linkLength += len(nodeText(child))
+		}
+	})
+
+	return math.Min(float64(linkLength)/float64(textLength), 1)
+}
+
+func nodeText(node *html.Node) string {
+	var builder strings.Builder
+
+	walkNodes(node, func(child *html.Node) {
+		if child.Type == html.TextNode {
+			builder.WriteString(child.Data)
+		}
+	})
+
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+func attr(node *html.Node, name string) string {
+	for _, item := range node.Attr {
+		if item.Key == name {
+			return item.Val
+		}
+	}
+
+	return ""
+}
+
+// walkNodes - call function for node and all its descendants
+func walkNodes(node *html.Node, callback func(node *html.Node)) {
+	callback(node)
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walkNodes(child, callback)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"newshub-server/models"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	storyFirst  = "The council approved the new budget on Tuesday, after a long debate about schools, roads and parks."
+	storySecond = "Residents will see lower fees next year, while the library, the pool and the museum get more money."
+	storyThird  = "The mayor said the plan is fair, balanced and ready, and the vote was eleven to two in the end."
+)
+
+func TestExtractContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		want    []string
+		missing []string
+	}{
+		{
+			"article with navigation and footer",
+			`<html><body>
+				<nav><p>Home, News, Sport, Weather, Culture and other sections of the site</p></nav>
+				<article><h1>Budget</h1><p>` + storyFirst + `</p><p>` + storySecond + `</p></article>
+				<footer><p>Copyright, all rights reserved, contacts, terms and privacy policy</p></footer>
+			</body></html>`,
+			[]string{storyFirst, storySecond},
+			[]string{"Home, News", "Copyright"},
+		},
+		{
+			"content class wins over comments",
+			`<html><body>
+				<div class="post-content"><p>` + storyFirst + `</p><p>` + storySecond + `</p></div>
+				<div class="comments">
+					<p>First comment, I agree with this, the budget is good, really good, very good.</p>
+					<p>Second comment, I do not agree, the budget is bad, really bad, very bad indeed.</p>
+					<p>Third comment, who cares about it, nobody reads this, nobody, never, not at all.</p>
+				</div>
+			</body></html>`,
+			[]string{storyFirst, storySecond},
+			[]string{"comment"},
+		},
+		{
+			"links block loses to text",
+			`<html><body>
+				<div id="story"><p>` + storyFirst + `</p></div>
+				<div>
+					<p><a href="/a">Related story about the council and its members, part one</a></p>
+					<p><a href="/b">Related story about the council and its members, part two</a></p>
+					<p><a href="/c">Related story about the council and its members, part three</a></p>
+				</div>
+			</body></html>`,
+			[]string{storyFirst},
+			[]string{"Related story"},
+		},
+		{
+			"long sibling paragraphs are included",
+			`<html><body><div>
+				<p>` + storyFirst + `</p>
+				<div><p>` + storySecond + `</p><p>` + storyThird + `</p></div>
+				<p>` + storyThird + ` And more details follow.</p>
+			</div></body></html>`,
+			[]string{storySecond, storyThird, "And more details follow."},
+			nil,
+		},
+		{
+			"scripts, styles and comments are removed",
+			`<html><head><style>p { color: red }</style></head><body><main>
+				<p>` + storyFirst + `<script>track("view, page, user")</script></p>
+				<!-- hidden, comment, text -->
+				<p>` + storySecond + `</p>
+			</main></body></html>`,
+			[]string{storyFirst, storySecond},
+			[]string{"track", "color", "hidden"},
+		},
+		{
+			"table layout",
+			`<html><body><table><tr>
+				<td class="menu"><a href="/">Home</a> <a href="/news">News</a></td>
+				<td>` + storyFirst + ` ` + storySecond + `</td>
+			</tr></table></body></html>`,
+			[]string{storyFirst, storySecond},
+			[]string{"Home"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := extractContent([]byte(test.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, text := range test.want {
+				if !strings.Contains(content, text) {
+					t.Errorf("content does not contain %q:\n%s", text, content)
+				}
+			}
+			for _, text := range test.missing {
+				if strings.Contains(content, text) {
+					t.Errorf("content contains %q:\n%s", text, content)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractContentNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+	}{
+		{"empty page", ""},
+		{"short paragraphs", "<html><body><p>Hello</p><p>World</p></body></html>"},
+		{"only navigation", "<html><body><nav><p>" + storyFirst + "</p></nav></body></html>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if content, err := extractContent([]byte(test.page)); err == nil {
+				t.Errorf("content %q is extracted", content)
+			}
+		})
+	}
+}
+
+func TestInitialScore(t *testing.T) {
+	tests := []struct {
+		element string
+		score   float64
+	}{
+		{`<article>`, 10},
+		{`<div>`, 5},
+		{`<blockquote>`, 3},
+		{`<ul>`, -3},
+		{`<h2>`, -5},
+		{`<section>`, 0},
+		{`<div class="entry-content">`, 30},
+		{`<div id="sidebar">`, -20},
+		{`<div class="post" id="comments">`, 5},
+		{`<article class="post-body">`, 35},
+	}
+
+	for _, test := range tests {
+		t.Run(test.element, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader("<html><body>" + test.element + "text"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var node *html.Node
+			walkNodes(doc, func(child *html.Node) {
+				if node == nil && child.Type == html.ElementNode && child.Parent != nil && child.Parent.DataAtom == atom.Body {
+					node = child
+				}
+			})
+
+			if score := initialScore(node); score != test.score {
+				t.Errorf("score %.0f, want %.0f", score, test.score)
+			}
+		})
+	}
+}
+
+func TestIngestFullContentInBackground(t *testing.T) {
+	config := setupTestDb(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("<html><body><article><p>" + storyFirst + "</p><p>" + storySecond + "</p></article></body></html>"))
+	}))
+	defer server.Close()
+
+	sharedFetcherOnce.Do(func() {})
+	sharedFetcher = newTestFetcher("127.0.0.1")
+
+	feed := models.Feeds{UserId: 1, Name: "feed", Url: server.URL + "/feed", FullContent: true}
+	db.Create(&feed)
+
+	parsed := &models.ParsedFeed{Items: []models.ParsedItem{{Guid: "1", Title: "budget", Link: server.URL + "/budget"}}}
+	done := make(chan error)
+
+	go func() {
+		done <- NewUpdateService(config).ingest(feed, parsed)
+	}()
+
+	// page is not downloaded until release, ingest does not wait for it
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("ingest waits for article page")
+	}
+
+	close(release)
+
+	article := models.Articles{}
+	for i := 0; i < 100 && article.FullBody == ""; i++ {
+		time.Sleep(20 * time.Millisecond)
+		db.Where(&models.Articles{FeedId: feed.Id}).First(&article)
+	}
+
+	if !strings.Contains(article.FullBody, storySecond) {
+		t.Errorf("full content %q is not saved", article.FullBody)
+	}
+}
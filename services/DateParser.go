@@ -0,0 +1,279 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFutureDate - dates later than fetch time plus this value are treated as broken
+const maxFutureDate = 24 * time.Hour
+
+// dateLayouts - supported layouts, values are normalized before parsing:
+// weekday is removed, month names are english, named zones are replaced with offsets
+var dateLayouts = []string{
+	// RFC 822 / 1123 and variants
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04:05",
+	"2 January 2006 15:04",
+	"2 January 2006",
+	"January 2 2006 15:04:05",
+	"January 2 2006",
+	// 12-hour clock
+	"2 Jan 2006 3:04:05 PM -0700",
+	"2 Jan 2006 3:04 PM -0700",
+	"2 Jan 2006 3:04:05 PM",
+	"2 Jan 2006 3:04 PM",
+	"Jan 2 2006 3:04:05 PM -0700",
+	"Jan 2 2006 3:04 PM -0700",
+	"Jan 2 2006 3:04:05 PM",
+	"Jan 2 2006 3:04 PM",
+	"2006-01-02 3:04:05 PM",
+	"2006-01-02 3:04 PM",
+	"01/02/2006 3:04:05 PM",
+	"01/02/2006 3:04 PM",
+	// RFC 3339 / ISO 8601 and variants
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"Jan 2 15:04:05 2006",
+}
+
+// zoneOffsets - named time zones used in feeds
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"MSD":  "+0400",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// monthNames - localized month names and abbreviations, keys are lower case
+var monthNames = map[string]string{
+	// russian
+	"января": "Jan", "январь": "Jan", "янв": "Jan",
+	"февраля": "Feb", "февраль": "Feb", "фев": "Feb",
+	"марта": "Mar", "март": "Mar", "мар": "Mar",
+	"апреля": "Apr", "апрель": "Apr", "апр": "Apr",
+	"мая": "May", "май": "May",
+	"июня": "Jun", "июнь": "Jun", "июн": "Jun",
+	"июля": "Jul", "июль": "Jul", "июл": "Jul",
+	"августа": "Aug", "август": "Aug", "авг": "Aug",
+	"сентября": "Sep", "сентябрь": "Sep", "сен": "Sep", "сент": "Sep",
+	"октября": "Oct", "октябрь": "Oct", "окт": "Oct",
+	"ноября": "Nov", "ноябрь": "Nov", "ноя": "Nov",
+	"декабря": "Dec", "декабрь": "Dec", "дек": "Dec",
+	// german
+	"januar": "Jan", "jän": "Jan", "februar": "Feb", "märz": "Mar", "mär": "Mar", "mrz": "Mar",
+	"mai": "May", "juni": "Jun", "juli": "Jul", "oktober": "Oct", "okt": "Oct", "dezember": "Dec", "dez": "Dec",
+	// french
+	"janvier": "Jan", "janv": "Jan", "février": "Feb", "févr": "Feb", "fév": "Feb", "mars": "Mar",
+	"avril": "Apr", "avr": "Apr", "juin": "Jun", "juillet": "Jul", "juil": "Jul", "août": "Aug",
+	"septembre": "Sep", "octobre": "Oct", "novembre": "Nov", "décembre": "Dec", "déc": "Dec",
+	// spanish
+	"enero": "Jan", "ene": "Jan", "febrero": "Feb", "marzo": "Mar", "abril": "Apr", "abr": "Apr",
+	"mayo": "May", "junio": "Jun", "julio": "Jul", "agosto": "Aug", "ago": "Aug",
+	"septiembre": "Sep", "setiembre": "Sep", "octubre": "Oct", "noviembre": "Nov", "diciembre": "Dec", "dic": "Dec",
+	// italian
+	"gennaio": "Jan", "gen": "Jan", "febbraio": "Feb", "aprile": "Apr", "maggio": "May", "mag": "May",
+	"giugno": "Jun", "giu": "Jun", "luglio": "Jul", "lug": "Jul", "settembre": "Sep", "set": "Sep",
+	"ottobre": "Oct", "ott": "Oct", "dicembre": "Dec",
+	// portuguese
+	"janeiro": "Jan", "fevereiro": "Feb", "fev": "Feb", "março": "Mar", "maio": "May",
+	"junho": "Jun", "julho": "Jul", "setembro": "Sep", "outubro": "Oct", "out": "Oct",
+	"novembro": "Nov", "dezembro": "Dec",
+	// dutch
+	"januari": "Jan", "februari": "Feb", "maart": "Mar", "mei": "May", "augustus": "Aug",
+	"okt.": "Oct",
+}
+
+var (
+	dateWeekday   = regexp.MustCompile(`^\p{L}+\.?,?\s+`)
+	dateComment   = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+	dateGMTOffset = regexp.MustCompile(`(?i)\b(?:GMT|UTC)\s*([+-])(\d{1,2}):?(\d{2})?$`)
+	dateNamedZone = regexp.MustCompile(`\s([A-Za-z]{1,4})$`)
+	dateOrdinal   = regexp.MustCompile(`(\d)(?:st|nd|rd|th|\.)([\s,])`)
+	dateAt        = regexp.MustCompile(`(?i)\s(?:at|в|um|à|a las)\s`)
+	dateLetters   = regexp.MustCompile(`\p{L}+\.?`)
+	dateMeridiem  = regexp.MustCompile(`(?i)(\d:\d{2}(?::\d{2})?)\s*([ap])\.?\s?m\.?(\s|$)`)
+)
+
+// parseDate - parse publication date in RFC 822, RFC 3339 and common broken formats,
+// fallback is used for unknown formats and dates from the future
+func parseDate(value string, fallback time.Time) time.Time {
+	date, ok := parseDateValue(value)
+	if !ok || date.After(fallback.Add(maxFutureDate)) || date.Year() < 1970 {
+		return fallback
+	}
+
+	return date
+}
+
+func parseDateValue(value string) (time.Time, bool) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	// unix timestamp
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds > 1e11 {
+			seconds /= 1000
+		}
+
+		return time.Unix(seconds, 0), true
+	}
+
+	// layouts with named zones are not used, because unknown zone is parsed with zero offset
+	for _, layout := range []string{time.RFC3339, time.RFC1123Z} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+
+	normalized := normalizeDate(value)
+
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, normalized); err == nil {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// normalizeDate - convert date to form which can be parsed by standard layouts
+func normalizeDate(value string) string {
+	value = dateComment.ReplaceAllString(value, "")
+	value = dateAt.ReplaceAllString(value, " ")
+	value = dateOrdinal.ReplaceAllString(value, "$1$2")
+
+	// "10:00pm" and "10:00 p.m." to "10:00 PM"
+	value = dateMeridiem.ReplaceAllStringFunc(value, func(clock string) string {
+		parts := dateMeridiem.FindStringSubmatch(clock)
+
+		return parts[1] + " " + strings.ToUpper(parts[2]) + "M" + parts[3]
+	})
+
+	// "GMT+3" and "UTC+03:00" to numeric offset
+	value = dateGMTOffset.ReplaceAllStringFunc(value, func(offset string) string {
+		parts := dateGMTOffset.FindStringSubmatch(offset)
+		hours, _ := strconv.Atoi(parts[2])
+		minutes, _ := strconv.Atoi(parts[3])
+
+		return parts[1] + twoDigits(hours) + twoDigits(minutes)
+	})
+
+	// leading weekday is removed, word without comma can be a month name
+	value = dateWeekday.ReplaceAllStringFunc(value, func(weekday string) string {
+		name := strings.TrimSpace(weekday)
+		if !strings.HasSuffix(name, ",") && englishMonth(name) != "" {
+			return weekday
+		}
+
+		return ""
+	})
+
+	// localized and english month names
+	value = dateLetters.ReplaceAllStringFunc(value, func(word string) string {
+		if month := englishMonth(word); month != "" {
+			return month
+		}
+
+		return word
+	})
+	value = strings.NewReplacer(",", " ", " г.", " ", " de ", " ").Replace(value)
+	value = strings.Join(strings.Fields(value), " ")
+
+	// named zone to numeric offset
+	if match := dateNamedZone.FindStringSubmatch(value); match != nil {
+		if offset, ok := zoneOffsets[strings.ToUpper(match[1])]; ok {
+			value = strings.TrimSuffix(value, match[1]) + offset
+		}
+	}
+
+	return value
+}
+
+// englishMonth - get english abbreviation of month name, empty for other words
+func englishMonth(word string) string {
+	lower := strings.ToLower(word)
+
+	if month, ok := monthNames[lower]; ok {
+		return month
+	}
+
+	lower = strings.TrimSuffix(lower, ".")
+	if month, ok := monthNames[lower]; ok {
+		return month
+	}
+
+	for month := time.January; month <= time.December; month++ {
+		name := strings.ToLower(month.String())
+		if lower == name || (len(lower) >= 3 && strings.HasPrefix(name, lower)) {
+			return month.String()[:3]
+		}
+	}
+
+	return ""
+}
+
+func twoDigits(value int) string {
+	if value < 10 {
+		return "0" + strconv.Itoa(value)
+	}
+
+	return strconv.Itoa(value)
+}
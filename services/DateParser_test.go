@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateNamedZones(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 GMT", "2006-01-02T15:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 UT", "2006-01-02T15:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 EST", "2006-01-02T20:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 EDT", "2006-01-02T19:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 PST", "2006-01-02T23:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 PDT", "2006-01-02T22:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 CET", "2006-01-02T14:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 CEST", "2006-01-02T13:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 MSK", "2006-01-02T12:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 JST", "2006-01-02T06:04:05Z"},
+		{"02 Jan 2006 15:04 est", "2006-01-02T20:04:00Z"},
+		{"Mon, 02 Jan 2006 15:04:05 -0500", "2006-01-02T20:04:05Z"},
+		{"Mon, 02 Jan 2006 15:04:05 GMT+3", "2006-01-02T12:04:05Z"},
+		{"2006-01-02T15:04:05-08:00", "2006-01-02T23:04:05Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			checkDate(t, test.value, test.want)
+		})
+	}
+}
+
+func TestParseDateTwelveHourClock(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Mar 5, 2020 10:00 AM", "2020-03-05T10:00:00Z"},
+		{"Mar 5, 2020 10:00 PM", "2020-03-05T22:00:00Z"},
+		{"Mar 5, 2020 12:00 AM", "2020-03-05T00:00:00Z"},
+		{"Mar 5, 2020 12:30 PM", "2020-03-05T12:30:00Z"},
+		{"March 5, 2020 at 9:15:30 pm", "2020-03-05T21:15:30Z"},
+		{"Thursday, March 5, 2020 9:15pm", "2020-03-05T21:15:00Z"},
+		{"5 Mar 2020 9:15 p.m.", "2020-03-05T21:15:00Z"},
+		{"Mar 5, 2020 10:00 AM EST", "2020-03-05T15:00:00Z"},
+		{"Thu, 5 Mar 2020 10:00 PM PST", "2020-03-06T06:00:00Z"},
+		{"Mar 5, 2020 10:00 AM GMT+2", "2020-03-05T08:00:00Z"},
+		{"2020-03-05 1:05 PM", "2020-03-05T13:05:00Z"},
+		{"03/05/2020 1:05:09 am", "2020-03-05T01:05:09Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			checkDate(t, test.value, test.want)
+		})
+	}
+}
+
+func TestParseDateFallback(t *testing.T) {
+	fallback := time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []string{
+		"",
+		"yesterday",
+		"Mar 5, 2020 13:00 PM",
+		"Mon, 02 Jan 2040 15:04:05 GMT",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			if date := parseDate(value, fallback); !date.Equal(fallback) {
+				t.Errorf("parseDate(%q) = %s, want fallback", value, date.UTC().Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func checkDate(t *testing.T, value string, want string) {
+	t.Helper()
+
+	expected, err := time.Parse(time.RFC3339, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date, ok := parseDateValue(value)
+	if !ok {
+		t.Fatalf("date %q is not parsed", value)
+	}
+	if !date.Equal(expected) {
+		t.Errorf("parseDateValue(%q) = %s, want %s", value, date.UTC().Format(time.RFC3339), want)
+	}
+}
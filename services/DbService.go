@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"newshub-server/models"
 	"time"
 
@@ -40,6 +41,14 @@ func getDb() *gorm.DB {
 			panic("open db error: " + err.Error())
 		}
 
+		// sqlite allows only one writer, feed updater works in several goroutines
+		sqlDB, err := sqliteDB.DB()
+		if err != nil {
+			panic("open db error: " + err.Error())
+		}
+
+		sqlDB.SetMaxOpenConns(1)
+
 		db = sqliteDB
 		return db
 	}
@@ -72,10 +81,102 @@ func migrate() {
 	db := getDb()
 	db.AutoMigrate(&models.Users{})
 	db.AutoMigrate(&models.Feeds{})
+	db.AutoMigrate(&models.Categories{})
+	migrateArticlesGuid(db)
 	db.AutoMigrate(&models.Articles{})
+	db.AutoMigrate(&models.Enclosures{})
+	db.AutoMigrate(&models.PlaybackPositions{})
+	migrateTagNames(db)
+	db.AutoMigrate(&models.Tags{})
+	db.AutoMigrate(&models.ArticleTags{})
+	db.AutoMigrate(&models.PurgedArticles{})
+	db.AutoMigrate(&models.SavedSearches{})
+	db.AutoMigrate(&models.FilterRules{})
 	db.AutoMigrate(&models.Settings{})
+	removeDuplicates(db, &models.VkNews{}, "idx_vknews_user_post", "UserId, GroupId, PostId")
 	db.AutoMigrate(&models.VkNews{})
 	db.AutoMigrate(&models.VkGroup{})
+	removeDuplicates(db, &models.TwitterNews{}, "idx_twitternews_user_tweet", "UserId, TweetId")
 	db.AutoMigrate(&models.TwitterNews{})
 	db.AutoMigrate(&models.TwitterSource{})
 }
+
+// migrateArticlesGuid - fill guid of existing articles before unique index is created,
+// such articles are matched by link on next feed update
+func migrateArticlesGuid(db *gorm.DB) {
+	migrator := db.Migrator()
+
+	if !migrator.HasTable(&models.Articles{}) || migrator.HasColumn(&models.Articles{}, "Guid") {
+		return
+	}
+	if err := migrator.AddColumn(&models.Articles{}, "Guid"); err != nil {
+		log.Println("add articles guid column error:", err)
+		return
+	}
+
+	err := db.Model(&models.Articles{}).
+		Where("Guid IS NULL OR Guid = ?", "").
+		UpdateColumn("Guid", gorm.Expr("CAST(Id AS VARCHAR(32))")).
+		Error
+	if err != nil {
+		log.Println("fill articles guid error:", err)
+	}
+}
+
+// migrateTagNames - merge tags of user with the same name before unique index is created,
+// articles of later tags get the first one
+func migrateTagNames(db *gorm.DB) {
+	migrator := db.Migrator()
+
+	if !migrator.HasTable(&models.Tags{}) || migrator.HasIndex(&models.Tags{}, "idx_tags_user_name") {
+		return
+	}
+
+	var tags []models.Tags
+	first := make(map[models.Tags]int64)
+
+	db.Order("Id").Find(&tags)
+
+	for _, tag := range tags {
+		key := models.Tags{UserId: tag.UserId, Name: tag.Name}
+		id, ok := first[key]
+		if !ok {
+			first[key] = tag.Id
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			tagged := tx.Model(&models.ArticleTags{}).Select("ArticleId").Where("TagId = ?", id)
+
+			// article can have both tags
+			if err := tx.Where("TagId = ? and ArticleId IN (?)", tag.Id, tagged).Delete(models.ArticleTags{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ArticleTags{}).Where("TagId = ?", tag.Id).UpdateColumn("TagId", id).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(&tag).Error
+		})
+		if err != nil {
+			log.Printf("merge tag %d into %d error: %s", tag.Id, id, err)
+		}
+	}
+}
+
+// removeDuplicates - delete duplicated rows before unique index is created,
+// row with the lowest id is kept from each group of columns
+func removeDuplicates(db *gorm.DB, model interface{}, index string, columns string) {
+	migrator := db.Migrator()
+
+	if !migrator.HasTable(model) || migrator.HasIndex(model, index) {
+		return
+	}
+
+	err := db.Where("Id NOT IN (?)", db.Model(model).Select("MIN(Id)").Group(columns)).
+		Delete(model).
+		Error
+	if err != nil {
+		log.Printf("remove duplicates before %s error: %s", index, err)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"newshub-server/models"
+)
+
+// setupTestDb - open empty sqlite database in temporary directory as package database
+func setupTestDb(t *testing.T) *models.Config {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "newshub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db = nil
+	cfg = &models.Config{
+		Driver:           "sqlite3",
+		ConnectionString: filepath.Join(dir, "test.db"),
+		PageSize:         20,
+		UpdateWorkers:    1,
+	}
+	getDb()
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+
+		db = nil
+		os.RemoveAll(dir)
+	})
+
+	return cfg
+}
+
+func TestRemoveDuplicates(t *testing.T) {
+	setupTestDb(t)
+
+	if err := db.Migrator().DropIndex(&models.VkNews{}, "idx_vknews_user_post"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&[]models.VkNews{
+		{UserId: 1, GroupId: 1, PostId: 1, Text: "first"},
+		{UserId: 1, GroupId: 1, PostId: 1, Text: "copy"},
+		{UserId: 1, GroupId: 2, PostId: 1, Text: "other group"},
+		{UserId: 2, GroupId: 1, PostId: 1, Text: "other user"},
+	})
+
+	removeDuplicates(db, &models.VkNews{}, "idx_vknews_user_post", "UserId, GroupId, PostId")
+
+	var texts []string
+	db.Model(&models.VkNews{}).Order("Id").Pluck("Text", &texts)
+
+	if len(texts) != 3 || texts[0] != "first" || texts[1] != "other group" || texts[2] != "other user" {
+		t.Errorf("posts %v, want [first other group other user]", texts)
+	}
+	if err := db.AutoMigrate(&models.VkNews{}); err != nil {
+		t.Errorf("unique index is not created: %s", err)
+	}
+}
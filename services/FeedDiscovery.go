@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"newshub-server/models"
+
+	"golang.org/x/net/html"
+)
+
+// feedTypes - link types of feeds on web pages
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+// feedPaths - common feed locations checked when page has no feed links
+var feedPaths = []string{
+	"/feed",
+	"/rss",
+	"/feed.xml",
+	"/rss.xml",
+	"/atom.xml",
+	"/index.xml",
+	"/feed.json",
+}
+
+// Feed error codes returned to clients
+const (
+	FeedErrorUnreachable = "unreachable"
+	FeedErrorNotFeed     = "not_feed"
+	FeedErrorParse       = "parse_error"
+)
+
+// FeedError - reason why url can not be used as feed.
+// Line is set for parse errors, HttpStatus for unsuccessful responses
+type FeedError struct {
+	Code       string
+	Message    string
+	Line       int
+	HttpStatus int
+}
+
+func (err *FeedError) Error() string {
+	return err.Message
+}
+
+// newFeedError - classify error of feed discovery
+func newFeedError(err error) *FeedError {
+	var feedErr *FeedError
+	var parseErr *ParseError
+	var fetchErr *FetchError
+
+	switch {
+	case errors.As(err, &feedErr):
+		return feedErr
+	case errors.As(err, &parseErr):
+		return &FeedError{Code: FeedErrorParse, Message: err.Error(), Line: parseErr.Line}
+	case errors.Is(err, errNotFeed):
+		return &FeedError{Code: FeedErrorNotFeed, Message: err.Error()}
+	case errors.As(err, &fetchErr):
+		return &FeedError{Code: FeedErrorUnreachable, Message: err.Error(), HttpStatus: fetchErr.StatusCode}
+	default:
+		return &FeedError{Code: FeedErrorUnreachable, Message: err.Error()}
+	}
+}
+
+// discoverFeed - get feed by url, feed links are searched if url is a web page.
+// Parsed feed is nil when page has several feeds, candidates are returned instead
+func discoverFeed(pageURL string) (string, *models.ParsedFeed, []models.FeedCandidate, error) {
+	result, err := fetchData(pageURL, "", "")
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	parsed, parseErr := parseFeed(result.Data)
+	if parseErr == nil {
+		return pageURL, parsed, nil, nil
+	}
+	if !isHTML(result.ContentType, result.Data) {
+		return "", nil, nil, parseErr
+	}
+
+	candidates := findFeedLinks(pageURL, result.Data)
+
+	switch len(candidates) {
+	case 0:
+		// probed feed is already downloaded
+		if feedURL, parsed := probeFeedPaths(pageURL); parsed != nil {
+			return feedURL, parsed, nil, nil
+		}
+
+		return "", nil, nil, fmt.Errorf("%w, feeds not found on page", errNotFeed)
+	case 1:
+		parsed, err := fetchFeed(candidates[0].Url)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		return candidates[0].Url, parsed, nil, nil
+	default:
+		return "", nil, candidates, nil
+	}
+}
+
+func isHTML(contentType string, data []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "text/html") {
+		return true
+	}
+
+	start := bytes.ToLower(bytes.TrimSpace(data))
+	if len(start) > 512 {
+		start = start[:512]
+	}
+
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.Contains(start, []byte("<html"))
+}
+
+// findFeedLinks - get feeds from <link rel="alternate"> tags
+func findFeedLinks(pageURL string, data []byte) []models.FeedCandidate {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	candidates := make([]models.FeedCandidate, 0)
+	found := make(map[string]bool)
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+
+	for {
+		tokenType := tokenizer.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			return candidates
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "body" {
+				return candidates
+			}
+			if token.Data != "link" && token.Data != "base" {
+				continue
+			}
+
+			attrs := make(map[string]string, len(token.Attr))
+			for _, attr := range token.Attr {
+				attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+			}
+
+			if token.Data == "base" {
+				if href, err := base.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+					base = href
+				}
+				continue
+			}
+
+			linkType := strings.ToLower(attrs["type"])
+			if !hasRel(attrs["rel"], "alternate") || !feedTypes[linkType] || attrs["href"] == "" {
+				continue
+			}
+
+			href, err := base.Parse(attrs["href"])
+			if err != nil || found[href.String()] {
+				continue
+			}
+
+			found[href.String()] = true
+			candidates = append(candidates, models.FeedCandidate{
+				Url:   href.String(),
+				Title: attrs["title"],
+				Type:  linkType,
+			})
+		}
+	}
+}
+
+func hasRel(rel string, value string) bool {
+	for _, item := range strings.Fields(strings.ToLower(rel)) {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// probeFeedPaths - check common feed locations of site, url and content of first found feed are returned
+func probeFeedPaths(pageURL string) (string, *models.ParsedFeed) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", nil
+	}
+
+	for _, path := range feedPaths {
+		feedURL := base.ResolveReference(&url.URL{Path: path}).String()
+
+		parsed, err := fetchFeed(feedURL)
+		if err != nil {
+			continue
+		}
+
+		return feedURL, parsed
+	}
+
+	return "", nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"newshub-server/models"
+)
+
+// discoveryServer - site with pages and feeds by path, requests are counted by path
+type discoveryServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func newDiscoveryServer(t *testing.T, pages map[string]string) *discoveryServer {
+	server := &discoveryServer{requests: make(map[string]int)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.requests[r.URL.Path]++
+		server.mutex.Unlock()
+
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+
+	sharedFetcherOnce.Do(func() {})
+	sharedFetcher = newTestFetcher("127.0.0.1")
+
+	return server
+}
+
+func (server *discoveryServer) requestCount(path string) int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.requests[path]
+}
+
+func TestDiscoverFeed(t *testing.T) {
+	rss := string(testRss("1", "2"))
+
+	tests := []struct {
+		name     string
+		pages    map[string]string
+		feedPath string
+		requests map[string]int
+	}{
+		{
+			"feed url",
+			map[string]string{"/": rss},
+			"/",
+			map[string]int{"/": 1},
+		},
+		{
+			"alternate link",
+			map[string]string{
+				"/":         `<html><head><link rel="alternate" type="application/rss+xml" href="/news/rss"></head><body></body></html>`,
+				"/news/rss": rss,
+				"/feed":     rss,
+			},
+			"/news/rss",
+			map[string]int{"/": 1, "/news/rss": 1, "/feed": 0},
+		},
+		{
+			"relative link with base",
+			map[string]string{
+				"/":               `<html><head><base href="/blog/"><link rel="Alternate Feed" type="Application/Atom+XML" href="feed.atom"></head></html>`,
+				"/blog/feed.atom": atomFixture,
+			},
+			"/blog/feed.atom",
+			map[string]int{"/": 1, "/blog/feed.atom": 1},
+		},
+		{
+			"links in body are ignored",
+			map[string]string{
+				"/":         `<html><head><title>blog</title></head><body><link rel="alternate" type="application/rss+xml" href="/body.xml"></body></html>`,
+				"/body.xml": rss,
+				"/rss.xml":  rss,
+			},
+			"/rss.xml",
+			map[string]int{"/body.xml": 0, "/rss.xml": 1},
+		},
+		{
+			"probe fallback",
+			map[string]string{
+				"/":         `<!DOCTYPE html><html><head><title>blog</title></head><body>no feeds</body></html>`,
+				"/rss":      `<html><body>not a feed</body></html>`,
+				"/feed.xml": rdfFixture,
+				"/rss.xml":  rss,
+			},
+			"/feed.xml",
+			map[string]int{"/": 1, "/feed": 1, "/rss": 1, "/feed.xml": 1, "/rss.xml": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newDiscoveryServer(t, test.pages)
+
+			feedURL, parsed, candidates, err := discoverFeed(server.URL + "/")
+			if err != nil {
+				t.Fatalf("discover error: %s", err)
+			}
+
+			if feedURL != server.URL+test.feedPath || parsed == nil || len(candidates) != 0 {
+				t.Errorf("feed %s with %d candidates, want %s", feedURL, len(candidates), server.URL+test.feedPath)
+			}
+			for path, count := range test.requests {
+				if requests := server.requestCount(path); requests != count {
+					t.Errorf("%d requests to %s, want %d", requests, path, count)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverFeedCandidates(t *testing.T) {
+	server := newDiscoveryServer(t, map[string]string{
+		"/": `<html><head>
+			<link rel="alternate" type="application/rss+xml" title="Posts" href="/rss.xml">
+			<link rel="alternate" type="application/atom+xml" title="Posts" href="/atom.xml">
+			<link rel="alternate" type="application/feed+json" title="JSON" href="https://other.example.com/feed.json">
+			<link rel="alternate" type="application/rss+xml" title="Copy" href="/rss.xml">
+			<link rel="alternate" type="text/html" hreflang="de" href="/de/">
+			<link rel="stylesheet" type="text/css" href="/style.css">
+			<link rel="alternate" type="application/rss+xml">
+		</head></html>`,
+		"/rss.xml": string(testRss("1")),
+	})
+
+	feedURL, parsed, candidates, err := discoverFeed(server.URL + "/")
+	if err != nil {
+		t.Fatalf("discover error: %s", err)
+	}
+	if feedURL != "" || parsed != nil {
+		t.Errorf("feed %s is chosen from several candidates", feedURL)
+	}
+
+	want := []models.FeedCandidate{
+		{Url: server.URL + "/rss.xml", Title: "Posts", Type: "application/rss+xml"},
+		{Url: server.URL + "/atom.xml", Title: "Posts", Type: "application/atom+xml"},
+		{Url: "https://other.example.com/feed.json", Title: "JSON", Type: "application/feed+json"},
+	}
+
+	if len(candidates) != len(want) {
+		t.Fatalf("candidates %+v, want %+v", candidates, want)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate %+v, want %+v", candidates[i], want[i])
+		}
+	}
+
+	// candidates are not downloaded until client chooses one
+	if count := server.requestCount("/rss.xml"); count != 0 {
+		t.Errorf("%d requests to candidate", count)
+	}
+}
+
+func TestDiscoverFeedErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]string
+		code  string
+	}{
+		{"page without feeds", map[string]string{"/": `<html><head><title>blog</title></head></html>`}, FeedErrorNotFeed},
+		{"not html and not feed", map[string]string{"/": `plain text`}, FeedErrorNotFeed},
+		{"broken feed", map[string]string{"/": `<?xml version="1.0"?><rss><channel><title>a</titel></channel></rss>`}, FeedErrorParse},
+		{"missing page", map[string]string{}, FeedErrorUnreachable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newDiscoveryServer(t, test.pages)
+
+			_, _, _, err := discoverFeed(server.URL + "/")
+			if err == nil {
+				t.Fatal("feed is discovered")
+			}
+
+			if code := newFeedError(err).Code; code != test.code {
+				t.Errorf("error %v with code %s, want %s", err, code, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,585 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"newshub-server/models"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	formatRss      = "rss"
+	formatRdf      = "rdf"
+	formatAtom     = "atom"
+	formatJSONFeed = "json"
+)
+
+// FeedParser - parser for one feed format
+type FeedParser interface {
+	// Format - short name of format
+	Format() string
+	// CanParse - check data by name of XML root element, root is empty for non XML data
+	CanParse(root string, data []byte) bool
+	// Parse - create normalized feed from data
+	Parse(data []byte) (*models.ParsedFeed, error)
+}
+
+var feedParsers = []FeedParser{
+	rssParser{},
+	rdfParser{},
+	atomParser{},
+	jsonFeedParser{},
+}
+
+// RegisterFeedParser - add parser for new format
+func RegisterFeedParser(parser FeedParser) {
+	feedParsers = append(feedParsers, parser)
+}
+
+// errNotFeed - data has no supported feed format
+var errNotFeed = errors.New("unknown feed format")
+
+// ParseError - data has supported feed format but can not be parsed, line is 0 when unknown
+type ParseError struct {
+	Format string
+	Line   int
+	Err    error
+}
+
+func (err *ParseError) Error() string {
+	if err.Line > 0 {
+		return fmt.Sprintf("%s parse error at line %d: %s", err.Format, err.Line, err.Err)
+	}
+
+	return fmt.Sprintf("%s parse error: %s", err.Format, err.Err)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// parseFeed - detect feed format and parse it
+func parseFeed(data []byte) (*models.ParsedFeed, error) {
+	root := rootElement(data)
+
+	for _, parser := range feedParsers {
+		if !parser.CanParse(root, data) {
+			continue
+		}
+
+		feed, err := parser.Parse(data)
+		if err != nil {
+			return nil, &ParseError{Format: parser.Format(), Line: errorLine(data, err), Err: err}
+		}
+
+		feed.Format = parser.Format()
+
+		return feed, nil
+	}
+
+	if root != "" {
+		return nil, fmt.Errorf("%w, root element: %s", errNotFeed, root)
+	}
+
+	return nil, errNotFeed
+}
+
+// errorLine - get line of XML or JSON syntax error, 0 for other errors
+func errorLine(data []byte, err error) int {
+	var offset int64
+
+	var xmlErr *xml.SyntaxError
+	var jsonErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &xmlErr):
+		return xmlErr.Line
+	case errors.As(err, &jsonErr):
+		offset = jsonErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return 0
+	}
+
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// rootElement - get name of XML root element, empty string for non XML data
+func rootElement(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '<' {
+		return ""
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local
+		}
+	}
+}
+
+// syndicationMinutes - get update interval from sy:updatePeriod and sy:updateFrequency
+func syndicationMinutes(period string, frequency int) int {
+	minutes := map[string]int{
+		"hourly":  60,
+		"daily":   60 * 24,
+		"weekly":  60 * 24 * 7,
+		"monthly": 60 * 24 * 30,
+		"yearly":  60 * 24 * 365,
+	}[strings.ToLower(strings.TrimSpace(period))]
+
+	if frequency <= 0 {
+		frequency = 1
+	}
+
+	return minutes / frequency
+}
+
+func decodeXML(data []byte, model interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	return decoder.Decode(model)
+}
+
+/*==============================================================================
+	RSS 2.0
+==============================================================================*/
+
+type rssParser struct{}
+
+func (rssParser) Format() string {
+	return formatRss
+}
+
+func (rssParser) CanParse(root string, data []byte) bool {
+	return root == "rss"
+}
+
+func (rssParser) Parse(data []byte) (*models.ParsedFeed, error) {
+	var xmlModel models.XMLFeed
+	if err := decodeXML(data, &xmlModel); err != nil {
+		return nil, err
+	}
+
+	feed := &models.ParsedFeed{
+		Title:       strings.TrimSpace(xmlModel.RssName),
+		Description: strings.TrimSpace(xmlModel.Description),
+		TTL:         xmlModel.TTL,
+		Items:       make([]models.ParsedItem, 0, len(xmlModel.Articles)),
+	}
+
+	// channel can contain both <link> and <atom:link> elements
+	for _, link := range xmlModel.Links {
+		switch {
+		case link.Rel == "hub" && feed.Hub == "":
+			feed.Hub = strings.TrimSpace(link.Href)
+		case link.Rel == "self" && feed.Self == "":
+			feed.Self = strings.TrimSpace(link.Href)
+		case link.XMLName.Space == "" && feed.Link == "":
+			feed.Link = strings.TrimSpace(link.Text)
+		}
+	}
+
+	if feed.TTL <= 0 {
+		feed.TTL = syndicationMinutes(xmlModel.UpdatePeriod, xmlModel.UpdateFrequency)
+	}
+
+	for _, article := range xmlModel.Articles {
+		// author element usually contains email, dc:creator contains name
+		author := strings.TrimSpace(article.Creator)
+		if author == "" {
+			author = strings.TrimSpace(article.Author)
+		}
+
+		feed.Items = append(feed.Items, models.ParsedItem{
+			Guid:       strings.TrimSpace(article.Guid),
+			Title:      strings.TrimSpace(article.Title),
+			Link:       strings.TrimSpace(article.Link),
+			Body:       strings.TrimSpace(article.Description),
+			Author:     author,
+			Date:       strings.TrimSpace(article.Date),
+			Enclosures: rssEnclosures(article),
+		})
+	}
+
+	return feed, nil
+}
+
+// rssEnclosures - get media files from enclosure and media:content elements
+func rssEnclosures(article models.XMLArticle) []models.ParsedEnclosure {
+	duration := parseDuration(article.Duration)
+	enclosures := make([]models.ParsedEnclosure, 0, len(article.Enclosures))
+
+	for _, enclosure := range article.Enclosures {
+		enclosures = appendEnclosure(enclosures, models.ParsedEnclosure{
+			Url:      strings.TrimSpace(enclosure.Url),
+			Type:     strings.TrimSpace(enclosure.Type),
+			Length:   parseLength(enclosure.Length),
+			Duration: duration,
+		})
+	}
+
+	media := article.Media
+	for _, group := range article.MediaGroups {
+		media = append(media, group.Contents...)
+	}
+
+	for _, content := range media {
+		enclosure := models.ParsedEnclosure{
+			Url:      strings.TrimSpace(content.Url),
+			Type:     strings.TrimSpace(content.Type),
+			Length:   parseLength(content.FileSize),
+			Duration: parseDuration(content.Duration),
+		}
+		if enclosure.Duration == 0 {
+			enclosure.Duration = duration
+		}
+
+		enclosures = appendEnclosure(enclosures, enclosure)
+	}
+
+	return enclosures
+}
+
+// appendEnclosure - add enclosure with unique url
+func appendEnclosure(enclosures []models.ParsedEnclosure, enclosure models.ParsedEnclosure) []models.ParsedEnclosure {
+	if enclosure.Url == "" {
+		return enclosures
+	}
+
+	for _, item := range enclosures {
+		if item.Url == enclosure.Url {
+			return enclosures
+		}
+	}
+
+	return append(enclosures, enclosure)
+}
+
+// parseLength - get size in bytes, invalid values are ignored
+func parseLength(value string) int64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || length < 0 {
+		return 0
+	}
+
+	return length
+}
+
+// parseDuration - get duration in seconds from "SS", "MM:SS" or "HH:MM:SS"
+func parseDuration(value string) int {
+	duration := 0.0
+
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0
+		}
+
+		duration = duration*60 + number
+	}
+
+	return int(duration)
+}
+
+/*==============================================================================
+	RSS 1.0 (RDF)
+==============================================================================*/
+
+type rdfParser struct{}
+
+func (rdfParser) Format() string {
+	return formatRdf
+}
+
+func (rdfParser) CanParse(root string, data []byte) bool {
+	return root == "RDF"
+}
+
+func (rdfParser) Parse(data []byte) (*models.ParsedFeed, error) {
+	var rdfModel models.RDFFeed
+	if err := decodeXML(data, &rdfModel); err != nil {
+		return nil, err
+	}
+
+	feed := &models.ParsedFeed{
+		Title:       strings.TrimSpace(rdfModel.RssName),
+		Link:        strings.TrimSpace(rdfModel.RssURL),
+		Description: strings.TrimSpace(rdfModel.Description),
+		TTL:         syndicationMinutes(rdfModel.UpdatePeriod, rdfModel.UpdateFrequency),
+		Items:       make([]models.ParsedItem, 0, len(rdfModel.Articles)),
+	}
+
+	for _, article := range rdfModel.Articles {
+		feed.Items = append(feed.Items, models.ParsedItem{
+			Guid:   strings.TrimSpace(article.About),
+			Title:  strings.TrimSpace(article.Title),
+			Link:   strings.TrimSpace(article.Link),
+			Body:   strings.TrimSpace(article.Description),
+			Author: strings.TrimSpace(article.Creator),
+			Date:   strings.TrimSpace(article.Date),
+		})
+	}
+
+	return feed, nil
+}
+
+/*==============================================================================
+	Atom 1.0
+==============================================================================*/
+
+type atomParser struct{}
+
+func (atomParser) Format() string {
+	return formatAtom
+}
+
+func (atomParser) CanParse(root string, data []byte) bool {
+	return root == "feed"
+}
+
+func (atomParser) Parse(data []byte) (*models.ParsedFeed, error) {
+	var atomModel models.AtomFeed
+	if err := decodeXML(data, &atomModel); err != nil {
+		return nil, err
+	}
+
+	// links are relative to xml:base of their elements
+	feedBase := atomBase("", atomModel.Base)
+	feed := &models.ParsedFeed{
+		Title:       strings.TrimSpace(atomModel.Title),
+		Link:        atomLink(atomModel.Links, feedBase),
+		Description: strings.TrimSpace(atomModel.Subtitle),
+		Items:       make([]models.ParsedItem, 0, len(atomModel.Entries)),
+	}
+
+	for _, link := range atomModel.Links {
+		if link.Rel == "hub" && feed.Hub == "" {
+			feed.Hub = atomHref(link, feedBase)
+		}
+		if link.Rel == "self" && feed.Self == "" {
+			feed.Self = atomHref(link, feedBase)
+		}
+	}
+
+	for _, entry := range atomModel.Entries {
+		date := entry.Published
+		if date == "" {
+			date = entry.Updated
+		}
+
+		entryBase := atomBase(feedBase, entry.Base)
+
+		feed.Items = append(feed.Items, models.ParsedItem{
+			Guid:       strings.TrimSpace(entry.Id),
+			Title:      strings.TrimSpace(entry.Title),
+			Link:       atomLink(entry.Links, entryBase),
+			Body:       atomContent(entry),
+			Author:     atomAuthor(entry.Authors, atomModel.Authors),
+			Date:       strings.TrimSpace(date),
+			Enclosures: atomEnclosures(entry.Links, entryBase),
+		})
+	}
+
+	return feed, nil
+}
+
+// atomLink - get alternate link, html links are preferred
+func atomLink(links []models.AtomLink, base string) string {
+	result := ""
+
+	for _, link := range links {
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return atomHref(link, base)
+		}
+		if result == "" {
+			result = atomHref(link, base)
+		}
+	}
+
+	return result
+}
+
+// atomBase - resolve xml:base of element against base of its parent
+func atomBase(parent string, base string) string {
+	base = strings.TrimSpace(base)
+	if base == "" {
+		return parent
+	}
+
+	return resolveURL(base, parent)
+}
+
+// atomHref - get link address resolved against xml:base,
+// links relative to document are resolved later with other item links
+func atomHref(link models.AtomLink, base string) string {
+	return resolveURL(strings.TrimSpace(link.Href), atomBase(base, link.Base))
+}
+
+// atomAuthor - get name of entry author, feed authors are used when entry has no author
+func atomAuthor(authors []models.AtomPerson, feedAuthors []models.AtomPerson) string {
+	for _, author := range append(authors, feedAuthors...) {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func atomEnclosures(links []models.AtomLink, base string) []models.ParsedEnclosure {
+	enclosures := make([]models.ParsedEnclosure, 0)
+
+	for _, link := range links {
+		if link.Rel != "enclosure" {
+			continue
+		}
+
+		enclosures = appendEnclosure(enclosures, models.ParsedEnclosure{
+			Url:    atomHref(link, base),
+			Type:   strings.TrimSpace(link.Type),
+			Length: parseLength(link.Length),
+		})
+	}
+
+	return enclosures
+}
+
+func atomContent(entry models.AtomEntry) string {
+	text := entry.Content
+	if strings.TrimSpace(text.Text) == "" && strings.TrimSpace(text.InnerXML) == "" {
+		text = entry.Summary
+	}
+	if text.Type == "xhtml" {
+		return strings.TrimSpace(text.InnerXML)
+	}
+
+	return strings.TrimSpace(text.Text)
+}
+
+/*==============================================================================
+	JSON Feed
+==============================================================================*/
+
+type jsonFeedParser struct{}
+
+func (jsonFeedParser) Format() string {
+	return formatJSONFeed
+}
+
+func (jsonFeedParser) CanParse(root string, data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+
+	return root == "" && len(trimmed) > 0 && trimmed[0] == '{' &&
+		bytes.Contains(trimmed, []byte("jsonfeed.org/version/"))
+}
+
+func (jsonFeedParser) Parse(data []byte) (*models.ParsedFeed, error) {
+	var jsonModel models.JSONFeed
+	if err := json.Unmarshal(data, &jsonModel); err != nil {
+		return nil, err
+	}
+
+	feed := &models.ParsedFeed{
+		Title:       strings.TrimSpace(jsonModel.Title),
+		Link:        strings.TrimSpace(jsonModel.HomePageURL),
+		Description: strings.TrimSpace(jsonModel.Description),
+		Self:        strings.TrimSpace(jsonModel.FeedURL),
+		Items:       make([]models.ParsedItem, 0, len(jsonModel.Items)),
+	}
+
+	for _, hub := range jsonModel.Hubs {
+		if strings.EqualFold(hub.Type, "WebSub") && feed.Hub == "" {
+			feed.Hub = strings.TrimSpace(hub.URL)
+		}
+	}
+
+	for _, item := range jsonModel.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+
+		body := item.ContentHTML
+		if body == "" {
+			body = item.ContentText
+		}
+		if body == "" {
+			body = item.Summary
+		}
+
+		date := item.DatePublished
+		if date == "" {
+			date = item.DateModified
+		}
+
+		enclosures := make([]models.ParsedEnclosure, 0, len(item.Attachments))
+		for _, attachment := range item.Attachments {
+			enclosures = appendEnclosure(enclosures, models.ParsedEnclosure{
+				Url:      strings.TrimSpace(attachment.URL),
+				Type:     strings.TrimSpace(attachment.MimeType),
+				Length:   attachment.Size,
+				Duration: int(attachment.Duration),
+			})
+		}
+
+		feed.Items = append(feed.Items, models.ParsedItem{
+			Guid:       strings.TrimSpace(string(item.Id)),
+			Title:      strings.TrimSpace(item.Title),
+			Link:       strings.TrimSpace(link),
+			Body:       strings.TrimSpace(body),
+			Author:     jsonFeedAuthor(item.Authors, item.Author, jsonModel.Authors, jsonModel.Author),
+			Date:       strings.TrimSpace(date),
+			Enclosures: enclosures,
+		})
+	}
+
+	return feed, nil
+}
+
+// jsonFeedAuthor - get name of item author, authors of version 1.1 are preferred
+// and feed authors are used when item has no author
+func jsonFeedAuthor(authors []models.JSONFeedAuthor, author *models.JSONFeedAuthor, feedAuthors []models.JSONFeedAuthor, feedAuthor *models.JSONFeedAuthor) string {
+	if author != nil {
+		authors = append(authors, *author)
+	}
+
+	authors = append(authors, feedAuthors...)
+
+	if feedAuthor != nil {
+		authors = append(authors, *feedAuthor)
+	}
+
+	for _, item := range authors {
+		if name := strings.TrimSpace(item.Name); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
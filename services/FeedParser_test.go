@@ -0,0 +1,396 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"newshub-server/models"
+)
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://example.com/blog/">
+	<title> Example blog </title>
+	<subtitle>Posts about examples</subtitle>
+	<link rel="self" href="feed.atom"/>
+	<link rel="hub" href="https://hub.example.com/"/>
+	<link rel="alternate" type="application/json" href="feed.json"/>
+	<link rel="alternate" type="text/html" href="./"/>
+	<author><name>Blog Team</name></author>
+	<entry>
+		<id>tag:example.com,2020:1</id>
+		<title>Content and summary</title>
+		<link rel="replies" href="1/comments"/>
+		<link rel="alternate" type="application/pdf" href="1.pdf"/>
+		<link href="1.html"/>
+		<author><name>Alice</name></author>
+		<published>2020-01-01T10:00:00Z</published>
+		<updated>2020-01-02T10:00:00Z</updated>
+		<summary>Short summary</summary>
+		<content type="html">&lt;p&gt;Full content&lt;/p&gt;</content>
+	</entry>
+	<entry xml:base="/archive/2020/">
+		<id>tag:example.com,2020:2</id>
+		<title>Only summary</title>
+		<link rel="alternate" type="application/pdf" href="2.pdf"/>
+		<link rel="enclosure" type="audio/mpeg" length="1024" href="media/2.mp3"/>
+		<link rel="enclosure" xml:base="https://cdn.example.com/" href="2.ogg"/>
+		<updated>2020-01-03T10:00:00Z</updated>
+		<summary>Summary only</summary>
+		<content></content>
+	</entry>
+	<entry>
+		<id>tag:example.com,2020:3</id>
+		<title type="html">Xhtml content</title>
+		<link rel="alternate" xml:base="https://other.example.com/" href="posts/3"/>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Xhtml <b>body</b></p></div></content>
+	</entry>
+</feed>`
+
+func TestJSONFeedItemID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		guid string
+	}{
+		{"string", `"item-1"`, "item-1"},
+		{"integer", `42`, "42"},
+		{"large integer", `1234567890123456789`, "1234567890123456789"},
+		{"float", `1.5`, "1.5"},
+		{"empty", `""`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := []byte(`{"version": "https://jsonfeed.org/version/1.1", "title": "Feed",
+				"items": [{"id": ` + test.id + `, "url": "https://example.com/1", "title": "Item"}]}`)
+
+			feed, err := parseFeed(data)
+			if err != nil {
+				t.Fatalf("parse error: %s", err)
+			}
+			if len(feed.Items) != 1 {
+				t.Fatalf("items count is %d, want 1", len(feed.Items))
+			}
+			if feed.Items[0].Guid != test.guid {
+				t.Errorf("guid is %q, want %q", feed.Items[0].Guid, test.guid)
+			}
+		})
+	}
+}
+
+func TestJSONFeedItemIDInvalid(t *testing.T) {
+	data := []byte(`{"version": "https://jsonfeed.org/version/1.1", "title": "Feed",
+		"items": [{"id": {"value": 1}, "url": "https://example.com/1"}]}`)
+
+	if _, err := parseFeed(data); err == nil {
+		t.Error("object id is parsed without error")
+	}
+}
+
+const rdfFixture = `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+	<channel rdf:about="https://example.com/">
+		<title>Old news</title>
+		<link>https://example.com/</link>
+		<description>News in RDF</description>
+		<sy:updatePeriod>daily</sy:updatePeriod>
+		<sy:updateFrequency>4</sy:updateFrequency>
+	</channel>
+	<item rdf:about="https://example.com/news/1">
+		<title>First</title>
+		<link>https://example.com/news/1</link>
+		<description>&lt;p&gt;First news&lt;/p&gt;</description>
+		<dc:creator>Bob</dc:creator>
+		<dc:date>2020-01-01T10:00:00+03:00</dc:date>
+	</item>
+	<item rdf:about="urn:news:2">
+		<title>Second</title>
+		<link> https://example.com/news/2 </link>
+	</item>
+</rdf:RDF>`
+
+const jsonFeedFixture = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "JSON blog",
+	"home_page_url": "https://example.com/",
+	"feed_url": "https://example.com/feed.json",
+	"description": "Posts in JSON",
+	"authors": [{"name": "Blog Team"}],
+	"hubs": [{"type": "rssCloud", "url": "https://cloud.example.com/"}, {"type": "WebSub", "url": "https://hub.example.com/"}],
+	"items": [
+		{
+			"id": "1",
+			"url": "https://example.com/1",
+			"title": "Html content",
+			"content_html": "<p>Html</p>",
+			"content_text": "Text",
+			"summary": "Summary",
+			"date_published": "2020-01-01T10:00:00Z",
+			"date_modified": "2020-01-02T10:00:00Z",
+			"authors": [{"name": ""}, {"name": "Alice"}]
+		},
+		{
+			"id": "2",
+			"external_url": "https://other.com/2",
+			"title": "Text content",
+			"content_text": "Only text",
+			"summary": "Summary",
+			"date_modified": "2020-01-02T10:00:00Z",
+			"author": {"name": "Carol"},
+			"attachments": [
+				{"url": "https://example.com/2.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 2048, "duration_in_seconds": 61.5},
+				{"url": "https://example.com/2.mp3", "mime_type": "audio/mpeg"}
+			]
+		},
+		{
+			"id": 3,
+			"url": "https://example.com/3",
+			"external_url": "https://other.com/3",
+			"summary": "Only summary"
+		}
+	]
+}`
+
+func TestParseRdf(t *testing.T) {
+	feed, err := parseFeed([]byte(rdfFixture))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	checkFeed(t, feed, models.ParsedFeed{
+		Format:      formatRdf,
+		Title:       "Old news",
+		Link:        "https://example.com/",
+		Description: "News in RDF",
+		TTL:         360,
+	})
+
+	checkItems(t, feed.Items, []models.ParsedItem{
+		{
+			Guid:   "https://example.com/news/1",
+			Title:  "First",
+			Link:   "https://example.com/news/1",
+			Body:   "<p>First news</p>",
+			Author: "Bob",
+			Date:   "2020-01-01T10:00:00+03:00",
+		},
+		{
+			Guid:  "urn:news:2",
+			Title: "Second",
+			Link:  "https://example.com/news/2",
+		},
+	})
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	feed, err := parseFeed([]byte(jsonFeedFixture))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	checkFeed(t, feed, models.ParsedFeed{
+		Format:      formatJSONFeed,
+		Title:       "JSON blog",
+		Link:        "https://example.com/",
+		Description: "Posts in JSON",
+		Hub:         "https://hub.example.com/",
+		Self:        "https://example.com/feed.json",
+	})
+
+	checkItems(t, feed.Items, []models.ParsedItem{
+		{
+			Guid:   "1",
+			Title:  "Html content",
+			Link:   "https://example.com/1",
+			Body:   "<p>Html</p>",
+			Author: "Alice",
+			Date:   "2020-01-01T10:00:00Z",
+		},
+		{
+			Guid:   "2",
+			Title:  "Text content",
+			Link:   "https://other.com/2",
+			Body:   "Only text",
+			Author: "Carol",
+			Date:   "2020-01-02T10:00:00Z",
+			Enclosures: []models.ParsedEnclosure{
+				{Url: "https://example.com/2.mp3", Type: "audio/mpeg", Length: 2048, Duration: 61},
+			},
+		},
+		{
+			Guid:   "3",
+			Link:   "https://example.com/3",
+			Body:   "Only summary",
+			Author: "Blog Team",
+		},
+	})
+}
+
+func TestParseFeedFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		err    error
+		line   int
+	}{
+		{"rss", `<?xml version="1.0"?><rss version="2.0"><channel><title>feed</title></channel></rss>`, formatRss, nil, 0},
+		{"rdf", rdfFixture, formatRdf, nil, 0},
+		{"atom", atomFixture, formatAtom, nil, 0},
+		{"json feed", jsonFeedFixture, formatJSONFeed, nil, 0},
+		{"json feed with spaces", "\n  " + jsonFeedFixture, formatJSONFeed, nil, 0},
+		{"html page", `<!DOCTYPE html><html><head><title>page</title></head></html>`, "", errNotFeed, 0},
+		{"other json", `{"title": "not a feed"}`, "", errNotFeed, 0},
+		{"empty", "", "", errNotFeed, 0},
+		{"broken atom", "<feed xmlns=\"http://www.w3.org/2005/Atom\">\n<entry>\n<title>a</titel>\n</entry></feed>", "", nil, 3},
+		{"broken json feed", "{\"version\": \"https://jsonfeed.org/version/1\",\n\"items\": [\n{\"id\": }]}", "", nil, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(test.data))
+
+			if test.format != "" {
+				if err != nil {
+					t.Fatalf("parse error: %s", err)
+				}
+				if feed.Format != test.format {
+					t.Errorf("format %q, want %q", feed.Format, test.format)
+				}
+				return
+			}
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("error %v, want %v", err, test.err)
+				}
+				return
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != test.line {
+				t.Errorf("error %v, want parse error at line %d", err, test.line)
+			}
+		})
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	feed, err := parseFeed([]byte(atomFixture))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	checkFeed(t, feed, models.ParsedFeed{
+		Format:      formatAtom,
+		Title:       "Example blog",
+		Link:        "https://example.com/blog/",
+		Description: "Posts about examples",
+		Hub:         "https://hub.example.com/",
+		Self:        "https://example.com/blog/feed.atom",
+	})
+
+	tests := []models.ParsedItem{
+		{
+			Guid:   "tag:example.com,2020:1",
+			Title:  "Content and summary",
+			Link:   "https://example.com/blog/1.html",
+			Body:   "<p>Full content</p>",
+			Author: "Alice",
+			Date:   "2020-01-01T10:00:00Z",
+		},
+		{
+			Guid:   "tag:example.com,2020:2",
+			Title:  "Only summary",
+			Link:   "https://example.com/archive/2020/2.pdf",
+			Body:   "Summary only",
+			Author: "Blog Team",
+			Date:   "2020-01-03T10:00:00Z",
+			Enclosures: []models.ParsedEnclosure{
+				{Url: "https://example.com/archive/2020/media/2.mp3", Type: "audio/mpeg", Length: 1024},
+				{Url: "https://cdn.example.com/2.ogg"},
+			},
+		},
+		{
+			Guid:   "tag:example.com,2020:3",
+			Title:  "Xhtml content",
+			Link:   "https://other.example.com/posts/3",
+			Body:   `<div xmlns="http://www.w3.org/1999/xhtml"><p>Xhtml <b>body</b></p></div>`,
+			Author: "Blog Team",
+		},
+	}
+
+	checkItems(t, feed.Items, tests)
+}
+
+func TestAtomLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []models.AtomLink
+		base  string
+		want  string
+	}{
+		{"no links", nil, "", ""},
+		{"link without rel", []models.AtomLink{{Href: "https://example.com/1"}}, "", "https://example.com/1"},
+		{"other rel is skipped", []models.AtomLink{{Rel: "related", Href: "https://example.com/r"}}, "", ""},
+		{"html is preferred", []models.AtomLink{
+			{Rel: "alternate", Type: "application/json", Href: "https://example.com/1.json"},
+			{Rel: "alternate", Type: "text/html", Href: "https://example.com/1"},
+		}, "", "https://example.com/1"},
+		{"first of other types", []models.AtomLink{
+			{Rel: "alternate", Type: "application/json", Href: "https://example.com/1.json"},
+			{Rel: "alternate", Type: "application/pdf", Href: "https://example.com/1.pdf"},
+		}, "", "https://example.com/1.json"},
+		{"relative to base", []models.AtomLink{{Href: "1"}}, "https://example.com/posts/", "https://example.com/posts/1"},
+		{"relative base of link", []models.AtomLink{{Base: "2020/", Href: "1"}}, "https://example.com/posts/", "https://example.com/posts/2020/1"},
+		{"absolute base of link", []models.AtomLink{{Base: "https://other.com/", Href: "1"}}, "https://example.com/", "https://other.com/1"},
+		{"absolute link", []models.AtomLink{{Href: "https://other.com/1"}}, "https://example.com/", "https://other.com/1"},
+		{"relative link without base", []models.AtomLink{{Href: "/posts/1"}}, "", "/posts/1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if link := atomLink(test.links, test.base); link != test.want {
+				t.Errorf("link %q, want %q", link, test.want)
+			}
+		})
+	}
+}
+
+func checkFeed(t *testing.T, feed *models.ParsedFeed, want models.ParsedFeed) {
+	t.Helper()
+
+	if feed.Format != want.Format || feed.Title != want.Title || feed.Link != want.Link || feed.Description != want.Description ||
+		feed.Hub != want.Hub || feed.Self != want.Self || feed.TTL != want.TTL {
+		feed.Items = nil
+		t.Errorf("feed %+v, want %+v", *feed, want)
+	}
+}
+
+func checkItems(t *testing.T, items []models.ParsedItem, want []models.ParsedItem) {
+	t.Helper()
+
+	if len(items) != len(want) {
+		t.Fatalf("items count is %d, want %d", len(items), len(want))
+	}
+
+	for i, item := range items {
+		expected := want[i]
+
+		if item.Guid != expected.Guid || item.Title != expected.Title || item.Link != expected.Link ||
+			item.Body != expected.Body || item.Author != expected.Author || item.Date != expected.Date {
+			t.Errorf("item %d is %+v, want %+v", i, item, expected)
+		}
+
+		if len(item.Enclosures) != len(expected.Enclosures) {
+			t.Errorf("item %d enclosures %+v, want %+v", i, item.Enclosures, expected.Enclosures)
+			continue
+		}
+		for j := range item.Enclosures {
+			if item.Enclosures[j] != expected.Enclosures[j] {
+				t.Errorf("item %d enclosure %+v, want %+v", i, item.Enclosures[j], expected.Enclosures[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"newshub-server/models"
+)
+
+// blockedNetworks - loopback, private, link-local and other non public addresses
+var blockedNetworks = parseNetworks([]string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+})
+
+var errBodyTooLarge = errors.New("response body is too large")
+
+// defaultUserAgent - User-Agent header when it is not set in config
+const defaultUserAgent = "NewsHub"
+
+// defaultThrottlePause - pause of requests to host which answered 429 without Retry-After
+const defaultThrottlePause = time.Minute
+
+// limiterIdleTime - limiters of hosts without requests during this time are removed
+const limiterIdleTime = 10 * time.Minute
+
+var (
+	sharedFetcher     *Fetcher
+	sharedFetcherOnce sync.Once
+)
+
+// Fetcher - HTTP client for remote content with timeouts, size and per-host limits,
+// connections to non public addresses are allowed only for hosts and networks from allowlist
+type Fetcher struct {
+	client          *http.Client
+	maxBytes        int64
+	allowedHosts    map[string]bool
+	allowedNets     []*net.IPNet
+	proxy           *url.URL
+	userAgent       string
+	hostConcurrency int
+	hostInterval    time.Duration
+	limiters        map[string]*hostLimiter
+	limitersMutex   sync.Mutex
+	limitersPruned  time.Time
+}
+
+// NewFetcher - create client with limits from config
+func NewFetcher(config *models.Config) *Fetcher {
+	fetcher := &Fetcher{
+		maxBytes:        config.FetchMaxBytes,
+		allowedHosts:    make(map[string]bool),
+		userAgent:       config.UserAgent,
+		hostConcurrency: config.HostConcurrency,
+		hostInterval:    time.Duration(config.HostInterval) * time.Millisecond,
+		limiters:        make(map[string]*hostLimiter),
+	}
+
+	if fetcher.userAgent == "" {
+		fetcher.userAgent = defaultUserAgent
+		if config.PublicUrl != "" {
+			fetcher.userAgent += " (+" + config.PublicUrl + ")"
+		}
+	}
+
+	for _, item := range config.FetchAllowlist {
+		item = strings.ToLower(strings.TrimSpace(item))
+
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			fetcher.allowedNets = append(fetcher.allowedNets, network)
+		} else if ip := net.ParseIP(item); ip != nil {
+			fetcher.allowedNets = append(fetcher.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else if item != "" {
+			fetcher.allowedHosts[item] = true
+		}
+	}
+
+	if config.FetchProxy != "" {
+		proxy, err := url.Parse(config.FetchProxy)
+		if err != nil {
+			log.Println("invalid fetch proxy error:", err)
+		} else {
+			// proxy is trusted, addresses of target hosts are checked before request
+			fetcher.proxy = proxy
+		}
+	}
+
+	connectTimeout := time.Duration(config.FetchConnectTimeout) * time.Second
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	safeDialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second, Control: fetcher.checkAddress}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err == nil && (fetcher.allowedHosts[strings.ToLower(host)] || fetcher.isProxy(host)) {
+				return dialer.DialContext(ctx, network, address)
+			}
+
+			// address is checked after name resolution, so DNS can not point to internal hosts
+			return safeDialer.DialContext(ctx, network, address)
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: time.Duration(config.FetchTimeout) * time.Second,
+	}
+
+	if fetcher.proxy != nil {
+		transport.Proxy = http.ProxyURL(fetcher.proxy)
+	}
+
+	maxRedirects := config.FetchMaxRedirects
+	fetcher.client = &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(config.FetchTimeout) * time.Second,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if err := checkScheme(request.URL); err != nil {
+				return err
+			}
+			if fetcher.proxy != nil {
+				if err := fetcher.checkHost(request.Context(), request.URL.Hostname()); err != nil {
+					return err
+				}
+			}
+
+			// body of previous response is closed, slot moves to host of redirect target
+			if slot, ok := request.Context().Value(hostSlotKey{}).(*hostSlot); ok {
+				slot.release()
+				return slot.acquire(request.Context(), fetcher.hostLimiter(request.URL.Hostname()), fetcher.hostInterval)
+			}
+
+			return nil
+		},
+	}
+
+	return fetcher
+}
+
+// getFetcher - shared client created from app config
+func getFetcher() *Fetcher {
+	sharedFetcherOnce.Do(func() {
+		sharedFetcher = NewFetcher(cfg)
+	})
+
+	return sharedFetcher
+}
+
+// Do - send request, body of response is limited by max size.
+// Requests to one host wait for free slot and interval, host is paused after 429 and 503 with Retry-After
+func (fetcher *Fetcher) Do(request *http.Request) (*http.Response, error) {
+	if err := checkScheme(request.URL); err != nil {
+		return nil, err
+	}
+	if fetcher.proxy != nil {
+		if err := fetcher.checkHost(request.Context(), request.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	if request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", fetcher.userAgent)
+	}
+
+	slot := &hostSlot{}
+	if err := slot.acquire(request.Context(), fetcher.hostLimiter(request.URL.Hostname()), fetcher.hostInterval); err != nil {
+		return nil, err
+	}
+
+	request = request.WithContext(context.WithValue(request.Context(), hostSlotKey{}, slot))
+
+	response, err := fetcher.client.Do(request)
+	if err != nil {
+		slot.release()
+		return nil, err
+	}
+
+	// limiter of last host in redirect chain
+	limiter := slot.limiter
+
+	switch retryAfter := parseRetryAfter(response.Header.Get("Retry-After")); {
+	case response.StatusCode == http.StatusTooManyRequests && retryAfter == 0:
+		limiter.pause(defaultThrottlePause)
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable:
+		limiter.pause(retryAfter)
+	}
+
+	// slot of host is busy until body is read
+	response.Body = &releasingBody{ReadCloser: response.Body, release: slot.release}
+
+	if fetcher.maxBytes > 0 {
+		if response.ContentLength > fetcher.maxBytes {
+			response.Body.Close()
+			return nil, errBodyTooLarge
+		}
+
+		response.Body = &limitedBody{ReadCloser: response.Body, left: fetcher.maxBytes}
+	}
+
+	return response, nil
+}
+
+// PostForm - send form to url
+func (fetcher *Fetcher) PostForm(link string, form url.Values) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return fetcher.Do(request)
+}
+
+// hostLimiter - get limiter of host, limiters are created on first request
+// and removed when host is idle
+func (fetcher *Fetcher) hostLimiter(host string) *hostLimiter {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	fetcher.limitersMutex.Lock()
+	defer fetcher.limitersMutex.Unlock()
+
+	if now.Sub(fetcher.limitersPruned) > limiterIdleTime {
+		fetcher.pruneLimiters(now)
+	}
+
+	limiter, ok := fetcher.limiters[host]
+	if !ok {
+		limiter = newHostLimiter(fetcher.hostConcurrency)
+		fetcher.limiters[host] = limiter
+	}
+
+	limiter.lastUsed = now
+
+	return limiter
+}
+
+// pruneLimiters - remove limiters of idle hosts, limiters mutex must be locked
+func (fetcher *Fetcher) pruneLimiters(now time.Time) {
+	for host, limiter := range fetcher.limiters {
+		if limiter.isIdle(now) {
+			delete(fetcher.limiters, host)
+		}
+	}
+
+	fetcher.limitersPruned = now
+}
+
+func (fetcher *Fetcher) isProxy(host string) bool {
+	return fetcher.proxy != nil && strings.EqualFold(fetcher.proxy.Hostname(), host)
+}
+
+// checkAddress - deny connection to non public address, it is called before connect
+func (fetcher *Fetcher) checkAddress(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", host)
+	}
+
+	return fetcher.checkIP(ip)
+}
+
+// checkHost - check all addresses of host, it is used when connections are made by proxy
+func (fetcher *Fetcher) checkHost(ctx context.Context, host string) error {
+	if fetcher.allowedHosts[strings.ToLower(host)] {
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		if err := fetcher.checkIP(address.IP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fetcher *Fetcher) checkIP(ip net.IP) error {
+	for _, allowed := range fetcher.allowedNets {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, blocked := range blockedNetworks {
+		if blocked.Contains(ip) {
+			return fmt.Errorf("address %s is not allowed", ip)
+		}
+	}
+
+	return nil
+}
+
+func checkScheme(link *url.URL) error {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q", link.Scheme)
+	}
+
+	return nil
+}
+
+func parseNetworks(cidrs []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic("invalid network " + cidr)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// hostSlot - slot of host limiter held by request, redirects release it and take slot of next host
+type hostSlot struct {
+	limiter *hostLimiter
+}
+
+type hostSlotKey struct{}
+
+func (slot *hostSlot) acquire(ctx context.Context, limiter *hostLimiter, interval time.Duration) error {
+	if err := limiter.acquire(ctx, interval); err != nil {
+		return err
+	}
+
+	slot.limiter = limiter
+
+	return nil
+}
+
+func (slot *hostSlot) release() {
+	if slot.limiter != nil {
+		slot.limiter.release()
+		slot.limiter = nil
+	}
+}
+
+// hostLimiter - concurrency and rate limit of requests to one host
+type hostLimiter struct {
+	slots       chan struct{}
+	mutex       sync.Mutex
+	next        time.Time
+	pausedUntil time.Time
+	lastUsed    time.Time // guarded by limiters mutex of fetcher
+}
+
+func newHostLimiter(concurrency int) *hostLimiter {
+	limiter := &hostLimiter{}
+	if concurrency > 0 {
+		limiter.slots = make(chan struct{}, concurrency)
+	}
+
+	return limiter
+}
+
+// acquire - wait for free slot and interval after previous request,
+// requests to paused host fail immediately so workers are not blocked
+func (limiter *hostLimiter) acquire(ctx context.Context, interval time.Duration) error {
+	if limiter.slots != nil {
+		select {
+		case limiter.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	limiter.mutex.Lock()
+	now := time.Now()
+
+	if now.Before(limiter.pausedUntil) {
+		retryAfter := limiter.pausedUntil.Sub(now)
+		limiter.mutex.Unlock()
+		limiter.release()
+
+		return &FetchError{
+			StatusCode: http.StatusTooManyRequests,
+			Message:    "429 host is paused by Retry-After",
+			RetryAfter: retryAfter,
+		}
+	}
+
+	wait := limiter.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+
+	limiter.next = now.Add(wait + interval)
+	limiter.mutex.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		limiter.release()
+		return ctx.Err()
+	}
+}
+
+func (limiter *hostLimiter) release() {
+	if limiter.slots != nil {
+		<-limiter.slots
+	}
+}
+
+// isIdle - check that host has no requests for idle time, no running requests and no pause
+func (limiter *hostLimiter) isIdle(now time.Time) bool {
+	if now.Sub(limiter.lastUsed) < limiterIdleTime || len(limiter.slots) > 0 {
+		return false
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return now.After(limiter.next) && now.After(limiter.pausedUntil)
+}
+
+// pause - stop requests to host for duration
+func (limiter *hostLimiter) pause(duration time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if until := time.Now().Add(duration); until.After(limiter.pausedUntil) {
+		limiter.pausedUntil = until
+	}
+}
+
+// releasingBody - response body which frees slot of host on close
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (body *releasingBody) Close() error {
+	body.once.Do(body.release)
+
+	return body.ReadCloser.Close()
+}
+
+// limitedBody - response body which returns error when max size is exceeded
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (body *limitedBody) Read(data []byte) (int, error) {
+	if body.left <= 0 {
+		// one more byte is read to distinguish body of max size from larger one
+		var probe [1]byte
+		n, err := body.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, errBodyTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(data)) > body.left {
+		data = data[:body.left]
+	}
+
+	n, err := body.ReadCloser.Read(data)
+	body.left -= int64(n)
+
+	return n, err
+}
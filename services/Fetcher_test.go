@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"newshub-server/models"
+)
+
+func newTestFetcher(allowlist ...string) *Fetcher {
+	return NewFetcher(&models.Config{
+		FetchTimeout:        5,
+		FetchConnectTimeout: 5,
+		FetchMaxRedirects:   5,
+		FetchAllowlist:      allowlist,
+	})
+}
+
+func TestFetcherCheckIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		ip        string
+		allowlist []string
+		allowed   bool
+	}{
+		{"public IPv4", "93.184.216.34", nil, true},
+		{"public IPv6", "2606:2800:220:1:248:1893:25c8:1946", nil, true},
+		{"loopback", "127.0.0.1", nil, false},
+		{"loopback range", "127.1.2.3", nil, false},
+		{"IPv6 loopback", "::1", nil, false},
+		{"unspecified", "0.0.0.0", nil, false},
+		{"IPv6 unspecified", "::", nil, false},
+		{"private 10/8", "10.1.2.3", nil, false},
+		{"private 172.16/12", "172.31.255.255", nil, false},
+		{"not private 172.32", "172.32.0.1", nil, true},
+		{"private 192.168/16", "192.168.1.1", nil, false},
+		{"shared address space", "100.64.0.1", nil, false},
+		{"link-local", "169.254.169.254", nil, false},
+		{"IPv6 link-local", "fe80::1", nil, false},
+		{"IPv6 unique local", "fd12:3456:789a::1", nil, false},
+		{"multicast", "224.0.0.1", nil, false},
+		{"IPv4-mapped loopback", "::ffff:127.0.0.1", nil, false},
+		{"IPv4-mapped private", "::ffff:10.0.0.1", nil, false},
+		{"IPv4-mapped link-local", "::ffff:169.254.169.254", nil, false},
+		{"IPv4-mapped public", "::ffff:93.184.216.34", nil, true},
+		{"NAT64 loopback", "64:ff9b::7f00:1", nil, false},
+		{"allowed network", "10.1.2.3", []string{"10.1.0.0/16"}, true},
+		{"outside of allowed network", "10.2.0.1", []string{"10.1.0.0/16"}, false},
+		{"allowed address", "192.168.1.5", []string{"192.168.1.5"}, true},
+		{"other than allowed address", "192.168.1.6", []string{"192.168.1.5"}, false},
+		{"IPv4-mapped allowed address", "::ffff:192.168.1.5", []string{"192.168.1.5"}, true},
+		{"allowed IPv6 network", "fd00::1", []string{"fd00::/8"}, true},
+		{"allowed host does not allow address", "127.0.0.1", []string{"localhost"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newTestFetcher(test.allowlist...).checkIP(net.ParseIP(test.ip))
+			if allowed := err == nil; allowed != test.allowed {
+				t.Errorf("address %s allowed: %t, want %t (%v)", test.ip, allowed, test.allowed, err)
+			}
+		})
+	}
+}
+
+func TestFetcherDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+
+	tests := []struct {
+		name      string
+		link      string
+		allowlist []string
+		allowed   bool
+	}{
+		{"loopback", server.URL, nil, false},
+		{"localhost", "http://localhost" + port, nil, false},
+		{"allowed address", server.URL, []string{"127.0.0.1"}, true},
+		{"allowed network", server.URL, []string{"127.0.0.0/8"}, true},
+		{"allowed host", "http://localhost" + port, []string{"localhost"}, true},
+		{"allowed host by address", server.URL, []string{"localhost"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkFetch(t, newTestFetcher(test.allowlist...), test.link, test.allowed)
+		})
+	}
+}
+
+func TestFetcherRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("private"))
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			http.Redirect(w, r, target.URL, http.StatusFound)
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/private", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	localhost := "http://localhost" + server.URL[strings.LastIndex(server.URL, ":"):]
+	// only host of first server is allowed, target server has the same address but other host
+	fetcher := newTestFetcher("localhost")
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"redirect to loopback", "/private"},
+		{"redirect chain to loopback", "/chain"},
+		{"redirect to link-local", "/metadata"},
+		{"redirect to other scheme", "/scheme"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkFetch(t, fetcher, localhost+test.path, false)
+		})
+	}
+}
+
+func TestFetcherRedirectLimiter(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/page", http.StatusFound)
+			return
+		}
+
+		w.Write([]byte("page"))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(&models.Config{
+		FetchTimeout:        5,
+		FetchConnectTimeout: 5,
+		FetchMaxRedirects:   5,
+		FetchAllowlist:      []string{"127.0.0.1", "localhost"},
+		HostConcurrency:     1,
+	})
+	target := strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/page"
+	slots := func(host string) int {
+		return len(fetcher.hostLimiter(host).slots)
+	}
+
+	// only slot of target host is busy
+	busy, err := fetcher.Do(newTestRequest(t, target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = fetcher.Do(newTestRequest(t, server.URL+"/redirect").WithContext(ctx))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want redirect waiting for slot of target host", err)
+	}
+	if slots("127.0.0.1") != 0 || slots("localhost") != 1 {
+		t.Errorf("%d and %d busy slots after failed redirect, want 0 and 1", slots("127.0.0.1"), slots("localhost"))
+	}
+
+	busy.Body.Close()
+
+	response, err := fetcher.Do(newTestRequest(t, server.URL+"/redirect"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots("127.0.0.1") != 0 || slots("localhost") != 1 {
+		t.Errorf("%d and %d busy slots while body is read, want 0 and 1", slots("127.0.0.1"), slots("localhost"))
+	}
+
+	response.Body.Close()
+
+	if slots("127.0.0.1") != 0 || slots("localhost") != 0 {
+		t.Errorf("%d and %d busy slots after body is closed, want 0 and 0", slots("127.0.0.1"), slots("localhost"))
+	}
+
+	// redirect to paused host fails as request to it
+	fetcher.hostLimiter("localhost").pause(time.Hour)
+
+	_, err = fetcher.Do(newTestRequest(t, server.URL+"/redirect"))
+
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("error %v, want paused host error", err)
+	}
+	if slots("127.0.0.1") != 0 || slots("localhost") != 0 {
+		t.Errorf("%d and %d busy slots after redirect to paused host, want 0 and 0", slots("127.0.0.1"), slots("localhost"))
+	}
+}
+
+func TestFetcherRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		paused     bool
+		minPause   time.Duration
+	}{
+		{"429 with seconds", http.StatusTooManyRequests, "120", true, 110 * time.Second},
+		{"429 with date", http.StatusTooManyRequests, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), true, 50 * time.Minute},
+		{"429 without Retry-After", http.StatusTooManyRequests, "", true, defaultThrottlePause - 10*time.Second},
+		{"503 with seconds", http.StatusServiceUnavailable, "60", true, 50 * time.Second},
+		{"503 without Retry-After", http.StatusServiceUnavailable, "", false, 0},
+		{"200 with Retry-After", http.StatusOK, "60", false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			fetcher := newTestFetcher("127.0.0.1")
+
+			response, err := fetcher.Do(newTestRequest(t, server.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			if response.StatusCode != test.status {
+				t.Fatalf("status %d, want %d", response.StatusCode, test.status)
+			}
+
+			response, err = fetcher.Do(newTestRequest(t, server.URL))
+			if !test.paused {
+				if err != nil {
+					t.Fatalf("host is paused: %s", err)
+				}
+				response.Body.Close()
+
+				if count := atomic.LoadInt32(&requests); count != 2 {
+					t.Errorf("server got %d requests, want 2", count)
+				}
+				return
+			}
+
+			var fetchErr *FetchError
+			if !errors.As(err, &fetchErr) {
+				t.Fatalf("error %v, want paused host error", err)
+			}
+			if fetchErr.StatusCode != http.StatusTooManyRequests || fetchErr.RetryAfter < test.minPause {
+				t.Errorf("error status %d and retry after %s, want 429 and at least %s",
+					fetchErr.StatusCode, fetchErr.RetryAfter, test.minPause)
+			}
+			if count := atomic.LoadInt32(&requests); count != 1 {
+				t.Errorf("server got %d requests, want 1", count)
+			}
+
+			// limiter of other host is not paused
+			checkFetch(t, fetcher, strings.Replace(server.URL, "127.0.0.1", "localhost", 1), true)
+		})
+	}
+}
+
+func TestFetcherPruneLimiters(t *testing.T) {
+	fetcher := newTestFetcher()
+	old := time.Now().Add(-2 * limiterIdleTime)
+
+	idle := fetcher.hostLimiter("idle.example.com")
+	busy := fetcher.hostLimiter("busy.example.com")
+	paused := fetcher.hostLimiter("paused.example.com")
+	fetcher.hostLimiter("recent.example.com")
+
+	busy.slots = make(chan struct{}, 1)
+	busy.slots <- struct{}{}
+	paused.pause(time.Hour)
+
+	for _, limiter := range []*hostLimiter{idle, busy, paused} {
+		limiter.lastUsed = old
+	}
+
+	fetcher.limitersPruned = old
+	fetcher.hostLimiter("new.example.com")
+
+	tests := []struct {
+		host string
+		kept bool
+	}{
+		{"idle.example.com", false},
+		{"busy.example.com", true},
+		{"paused.example.com", true},
+		{"recent.example.com", true},
+		{"new.example.com", true},
+	}
+
+	for _, test := range tests {
+		if _, ok := fetcher.limiters[test.host]; ok != test.kept {
+			t.Errorf("limiter of %s kept: %t, want %t", test.host, ok, test.kept)
+		}
+	}
+
+	// limiters are not checked again until idle time passes
+	idle = fetcher.hostLimiter("idle.example.com")
+	idle.lastUsed = old
+	fetcher.hostLimiter("new.example.com")
+
+	if _, ok := fetcher.limiters["idle.example.com"]; !ok {
+		t.Error("limiters are pruned before idle time")
+	}
+}
+
+func newTestRequest(t *testing.T, link string) *http.Request {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+func checkFetch(t *testing.T, fetcher *Fetcher, link string, allowed bool) {
+	t.Helper()
+
+	response, err := fetcher.Do(newTestRequest(t, link))
+	if err == nil {
+		response.Body.Close()
+	}
+
+	if (err == nil) != allowed {
+		t.Errorf("request to %s allowed: %t, want %t (%v)", link, err == nil, allowed, err)
+	}
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+)
+
+// Sources of filter rules, rule without source is applied to all items
+const (
+	FilterSourceRss     = "rss"
+	FilterSourceVk      = "vk"
+	FilterSourceTwitter = "twitter"
+)
+
+// Actions of filter rules, vk posts and tweets have no read state and tags, so only discard is applied to them
+const (
+	FilterActionRead     = "read"
+	FilterActionBookmark = "bookmark"
+	FilterActionTag      = "tag"
+	FilterActionDiscard  = "discard"
+)
+
+// Operations of filter conditions
+const (
+	FilterOpAnd      = "and"
+	FilterOpOr       = "or"
+	FilterOpNot      = "not"
+	FilterOpContains = "contains"
+	FilterOpRegex    = "regex"
+)
+
+// maxConditionDepth - nesting limit of rule conditions
+const maxConditionDepth = 10
+
+// count of latest items checked in dry run
+const (
+	filterTestItems    = 50
+	maxFilterTestItems = 1000
+)
+
+var filterFields = map[string]bool{
+	"title":  true,
+	"body":   true,
+	"link":   true,
+	"author": true,
+	"feed":   true,
+}
+
+// filterRule - rule with compiled regular expressions of conditions
+type filterRule struct {
+	models.FilterRules
+	regexps map[string]*regexp.Regexp
+}
+
+// FilterService - user rules for new articles, vk posts and tweets
+type FilterService struct {
+	db     *gorm.DB
+	config *models.Config
+}
+
+func NewFilterService(config *models.Config) *FilterService {
+	return &FilterService{
+		db:     getDb(),
+		config: config,
+	}
+}
+
+func (service *FilterService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// GetAll - get rules of user in order of applying
+func (service *FilterService) GetAll(userID int64) []models.FilterRules {
+	var rules []models.FilterRules
+	service.db.Where(&models.FilterRules{UserId: userID}).Order("Id").Find(&rules)
+
+	return rules
+}
+
+// Get - get rule of user, Id is 0 when rule is not found
+func (service *FilterService) Get(id int64, userID int64) models.FilterRules {
+	rule := models.FilterRules{}
+	service.db.Where(&models.FilterRules{Id: id, UserId: userID}).Find(&rule)
+
+	return rule
+}
+
+// Create - add new rule
+func (service *FilterService) Create(data models.FilterRuleData, userID int64) (models.FilterRules, error) {
+	rule := models.FilterRules{UserId: userID}
+
+	if err := fillRule(&rule, data); err != nil {
+		return rule, err
+	}
+
+	err := service.db.Create(&rule).Error
+
+	return rule, err
+}
+
+// Update - change rule, nil is returned when rule is not found
+func (service *FilterService) Update(id int64, data models.FilterRuleData, userID int64) (*models.FilterRules, error) {
+	rule := service.Get(id, userID)
+
+	if rule.Id == 0 {
+		return nil, nil
+	}
+	if err := fillRule(&rule, data); err != nil {
+		return nil, err
+	}
+	if err := service.db.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// Delete - remove rule
+func (service *FilterService) Delete(id int64, userID int64) error {
+	return service.db.Where(&models.FilterRules{Id: id, UserId: userID}).Delete(models.FilterRules{}).Error
+}
+
+// Test - check rule against latest items of user without applying its action
+func (service *FilterService) Test(data models.FilterRuleData, userID int64, count int) (*models.FilterTestResult, error) {
+	rule := models.FilterRules{UserId: userID}
+
+	if err := fillRule(&rule, data); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if count <= 0 {
+		count = filterTestItems
+	}
+	if count > maxFilterTestItems {
+		count = maxFilterTestItems
+	}
+
+	items := service.latestItems(rule.Source, userID, count)
+	result := &models.FilterTestResult{Checked: len(items), Items: []models.FilterTestItem{}}
+
+	for _, item := range items {
+		if compiled.match(item) {
+			result.Items = append(result.Items, item)
+		}
+	}
+
+	result.Matched = len(result.Items)
+
+	return result, nil
+}
+
+// latestItems - get latest items of source, items of every source are taken when source is empty
+func (service *FilterService) latestItems(source string, userID int64, count int) []models.FilterTestItem {
+	var items []models.FilterTestItem
+
+	if source == "" || source == FilterSourceRss {
+		var rows []models.FilterTestItem
+		service.db.Model(&models.Articles{}).
+			Joins("join feeds on articles.FeedId = feeds.Id").
+			Select("articles.Id as Id, articles.Title as Title, articles.Body as Body, articles.Link as Link, "+
+				"articles.Author as Author, feeds.Name as Feed").
+			Where("feeds.UserId = ?", userID).
+			Order("articles.Id desc").
+			Limit(count).
+			Scan(&rows)
+
+		items = appendItems(items, rows, FilterSourceRss)
+	}
+	if source == "" || source == FilterSourceVk {
+		var rows []models.FilterTestItem
+		service.db.Model(&models.VkNews{}).
+			Joins("left join vkgroups on vkgroups.Gid = vknews.GroupId and vkgroups.UserId = vknews.UserId").
+			Select("vknews.Id as Id, vknews.Text as Body, vknews.Link as Link, vkgroups.Name as Author, vkgroups.Name as Feed").
+			Where("vknews.UserId = ?", userID).
+			Order("vknews.Id desc").
+			Limit(count).
+			Scan(&rows)
+
+		items = appendItems(items, rows, FilterSourceVk)
+	}
+	if source == "" || source == FilterSourceTwitter {
+		var rows []models.FilterTestItem
+		service.db.Model(&models.TwitterNews{}).
+			Joins("left join twittersource on twittersource.Id = twitternews.SourceId").
+			Select("twitternews.Id as Id, twitternews.Text as Body, twitternews.ExpandedUrl as Link, "+
+				"twittersource.ScreenName as Author, twittersource.Name as Feed").
+			Where("twitternews.UserId = ?", userID).
+			Order("twitternews.Id desc").
+			Limit(count).
+			Scan(&rows)
+
+		items = appendItems(items, rows, FilterSourceTwitter)
+	}
+
+	return items
+}
+
+func appendItems(items []models.FilterTestItem, rows []models.FilterTestItem, source string) []models.FilterTestItem {
+	for _, row := range rows {
+		row.Source = source
+		items = append(items, row)
+	}
+
+	return items
+}
+
+// fillRule - set fields of rule from request data and validate them
+func fillRule(rule *models.FilterRules, data models.FilterRuleData) error {
+	rule.Name = strings.TrimSpace(data.Name)
+	rule.Source = data.Source
+	rule.Condition = data.Condition
+	rule.Action = data.Action
+	rule.Tag = strings.TrimSpace(data.Tag)
+	rule.IsEnabled = data.IsEnabled
+
+	switch rule.Source {
+	case "", FilterSourceRss, FilterSourceVk, FilterSourceTwitter:
+	default:
+		return fmt.Errorf("unknown source: %s", rule.Source)
+	}
+
+	switch rule.Action {
+	case FilterActionRead, FilterActionBookmark, FilterActionDiscard:
+		rule.Tag = ""
+	case FilterActionTag:
+		if rule.Tag == "" {
+			return errors.New("tag of rule is empty")
+		}
+	default:
+		return fmt.Errorf("unknown action: %s", rule.Action)
+	}
+
+	if (rule.Source == FilterSourceVk || rule.Source == FilterSourceTwitter) && rule.Action != FilterActionDiscard {
+		return fmt.Errorf("action %s is not supported for source %s", rule.Action, rule.Source)
+	}
+
+	_, err := compileRule(*rule)
+
+	return err
+}
+
+// compileRule - validate condition of rule and compile its regular expressions
+func compileRule(rule models.FilterRules) (*filterRule, error) {
+	compiled := &filterRule{FilterRules: rule, regexps: make(map[string]*regexp.Regexp)}
+
+	if err := compiled.compile(rule.Condition, 1); err != nil {
+		return nil, err
+	}
+
+	return compiled, nil
+}
+
+func (rule *filterRule) compile(condition models.FilterCondition, depth int) error {
+	if depth > maxConditionDepth {
+		return errors.New("condition is nested too deep")
+	}
+
+	switch condition.Op {
+	case FilterOpAnd, FilterOpOr:
+		if len(condition.Conditions) == 0 {
+			return fmt.Errorf("condition %s has no nested conditions", condition.Op)
+		}
+	case FilterOpNot:
+		if len(condition.Conditions) != 1 {
+			return errors.New("condition not must have one nested condition")
+		}
+	case FilterOpContains, FilterOpRegex:
+		if !filterFields[condition.Field] {
+			return fmt.Errorf("unknown field: %s", condition.Field)
+		}
+		if condition.Pattern == "" {
+			return errors.New("pattern of condition is empty")
+		}
+		if condition.Op == FilterOpRegex {
+			expression, err := regexp.Compile(condition.Pattern)
+			if err != nil {
+				return err
+			}
+
+			rule.regexps[condition.Pattern] = expression
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown operation: %s", condition.Op)
+	}
+
+	for _, nested := range condition.Conditions {
+		if err := rule.compile(nested, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// match - check item by condition of rule, "contains" ignores case
+func (rule *filterRule) match(item models.FilterTestItem) bool {
+	return rule.matchCondition(rule.Condition, item)
+}
+
+func (rule *filterRule) matchCondition(condition models.FilterCondition, item models.FilterTestItem) bool {
+	switch condition.Op {
+	case FilterOpAnd:
+		for _, nested := range condition.Conditions {
+			if !rule.matchCondition(nested, item) {
+				return false
+			}
+		}
+
+		return true
+	case FilterOpOr:
+		for _, nested := range condition.Conditions {
+			if rule.matchCondition(nested, item) {
+				return true
+			}
+		}
+
+		return false
+	case FilterOpNot:
+		return !rule.matchCondition(condition.Conditions[0], item)
+	case FilterOpContains:
+		return strings.Contains(strings.ToLower(itemField(item, condition.Field)), strings.ToLower(condition.Pattern))
+	case FilterOpRegex:
+		return rule.regexps[condition.Pattern].MatchString(itemField(item, condition.Field))
+	}
+
+	return false
+}
+
+func itemField(item models.FilterTestItem, field string) string {
+	switch field {
+	case "title":
+		return item.Title
+	case "body":
+		return item.Body
+	case "link":
+		return item.Link
+	case "author":
+		return item.Author
+	case "feed":
+		return item.Feed
+	}
+
+	return ""
+}
+
+// loadRules - get enabled rules of user for source, invalid rules are skipped
+func loadRules(db *gorm.DB, userID int64, source string) []*filterRule {
+	var rules []models.FilterRules
+	db.Where("UserId = ? and IsEnabled = ? and (Source = ? or Source = ?)", userID, true, source, "").
+		Order("Id").
+		Find(&rules)
+
+	result := make([]*filterRule, 0, len(rules))
+	for _, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			log.Printf("filter rule %d error: %s", rule.Id, err)
+			continue
+		}
+
+		result = append(result, compiled)
+	}
+
+	return result
+}
+
+// matchRules - get rules matched by item, rules after discarding rule are not checked
+func matchRules(rules []*filterRule, item models.FilterTestItem) []*filterRule {
+	var matched []*filterRule
+
+	for _, rule := range rules {
+		if !rule.match(item) {
+			continue
+		}
+
+		matched = append(matched, rule)
+
+		if rule.Action == FilterActionDiscard {
+			break
+		}
+	}
+
+	return matched
+}
+
+// isDiscarded - check if item is discarded by rules
+func isDiscarded(rules []*filterRule, item models.FilterTestItem) bool {
+	matched := matchRules(rules, item)
+
+	return len(matched) > 0 && matched[len(matched)-1].Action == FilterActionDiscard
+}
+
+// filterArticles - apply rules to new articles of feed, discarded articles are removed
+// and names of tags are returned for every kept article
+func filterArticles(db *gorm.DB, feed models.Feeds, articles []models.Articles) ([]models.Articles, [][]string) {
+	tags := make([][]string, len(articles))
+	rules := loadRules(db, feed.UserId, FilterSourceRss)
+
+	if len(rules) == 0 {
+		return articles, tags
+	}
+
+	kept := articles[:0]
+	tags = tags[:0]
+
+	for _, article := range articles {
+		item := models.FilterTestItem{
+			Source: FilterSourceRss,
+			Title:  article.Title,
+			Body:   article.Body,
+			Link:   article.Link,
+			Author: article.Author,
+			Feed:   feed.Name,
+		}
+
+		matched := matchRules(rules, item)
+		if len(matched) > 0 && matched[len(matched)-1].Action == FilterActionDiscard {
+			continue
+		}
+
+		var names []string
+		for _, rule := range matched {
+			switch rule.Action {
+			case FilterActionRead:
+				article.IsRead = true
+			case FilterActionBookmark:
+				article.IsBookmark = true
+			case FilterActionTag:
+				names = append(names, rule.Tag)
+			}
+		}
+
+		kept = append(kept, article)
+		tags = append(tags, names)
+	}
+
+	return kept, tags
+}
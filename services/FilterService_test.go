@@ -0,0 +1,126 @@
+package services
+
+import (
+	"testing"
+
+	"newshub-server/models"
+)
+
+func TestFillRuleActions(t *testing.T) {
+	condition := models.FilterCondition{Op: FilterOpContains, Field: "body", Pattern: "ad"}
+
+	tests := []struct {
+		source string
+		action string
+		valid  bool
+	}{
+		{"", FilterActionRead, true},
+		{"", FilterActionTag, true},
+		{"", FilterActionDiscard, true},
+		{FilterSourceRss, FilterActionRead, true},
+		{FilterSourceRss, FilterActionBookmark, true},
+		{FilterSourceRss, FilterActionTag, true},
+		{FilterSourceRss, FilterActionDiscard, true},
+		{FilterSourceVk, FilterActionRead, false},
+		{FilterSourceVk, FilterActionBookmark, false},
+		{FilterSourceVk, FilterActionTag, false},
+		{FilterSourceVk, FilterActionDiscard, true},
+		{FilterSourceTwitter, FilterActionRead, false},
+		{FilterSourceTwitter, FilterActionBookmark, false},
+		{FilterSourceTwitter, FilterActionTag, false},
+		{FilterSourceTwitter, FilterActionDiscard, true},
+		{"mail", FilterActionDiscard, false},
+		{FilterSourceRss, "delete", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source+" "+test.action, func(t *testing.T) {
+			rule := models.FilterRules{}
+			data := models.FilterRuleData{
+				Name:      "rule",
+				Source:    test.source,
+				Condition: condition,
+				Action:    test.action,
+				Tag:       "tag",
+			}
+
+			if err := fillRule(&rule, data); (err == nil) != test.valid {
+				t.Errorf("rule is valid: %t, want %t (%v)", err == nil, test.valid, err)
+			}
+		})
+	}
+}
+
+func TestAddNewsDiscarded(t *testing.T) {
+	config := setupTestDb(t)
+	filters := NewFilterService(config)
+
+	for _, source := range []string{FilterSourceVk, FilterSourceTwitter} {
+		_, err := filters.Create(models.FilterRuleData{
+			Name:      source,
+			Source:    source,
+			Condition: models.FilterCondition{Op: FilterOpContains, Field: "body", Pattern: "sponsored"},
+			Action:    FilterActionDiscard,
+			IsEnabled: true,
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ids from request are ignored, so items of other users are not overwritten
+	db.Create(&models.VkNews{UserId: 2, Text: "other user"})
+	db.Create(&models.TwitterNews{UserId: 2, Text: "other user"})
+
+	tests := []struct {
+		name  string
+		add   func() (int, error)
+		model interface{}
+	}{
+		{"vk", func() (int, error) {
+			return NewVkService(config).AddNews(1, []models.VkNews{
+				{Id: 1, GroupId: 1, PostId: 1, Text: "news"},
+				{GroupId: 1, PostId: 2, Text: "Sponsored post"},
+				{GroupId: 1, PostId: 3, Text: "other news"},
+			})
+		}, &models.VkNews{}},
+		{"twitter", func() (int, error) {
+			return NewTwitterService(config).AddNews(1, []models.TwitterNews{
+				{Id: 1, SourceId: 1, TweetId: 1, Text: "news"},
+				{SourceId: 1, TweetId: 2, Text: "sponsored tweet"},
+				{SourceId: 1, TweetId: 3, Text: "other news"},
+			})
+		}, &models.TwitterNews{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			count, err := test.add()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("%d items are saved, want 2", count)
+			}
+
+			var userItems, otherItems int64
+			db.Model(test.model).Where("UserId = ?", 1).Count(&userItems)
+			db.Model(test.model).Where("UserId = ? and Text = ?", 2, "other user").Count(&otherItems)
+
+			if userItems != 2 || otherItems != 1 {
+				t.Errorf("user has %d items, other user has %d items, want 2 and 1", userItems, otherItems)
+			}
+
+			// batch sent again is not duplicated
+			count, err = test.add()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db.Model(test.model).Where("UserId = ?", 1).Count(&userItems)
+			if count != 0 || userItems != 2 {
+				t.Errorf("%d items are saved again, user has %d items, want 0 and 2", count, userItems)
+			}
+		})
+	}
+}
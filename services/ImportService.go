@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"newshub-server/models"
+
+	"golang.org/x/net/html/charset"
+	"gorm.io/gorm"
+)
+
+// importJobLifetime - finished jobs are kept for status requests during this time
+const importJobLifetime = time.Hour
+
+// OPML import statuses of feeds
+const (
+	ImportAdded   = "added"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// OPML import job statuses
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+var (
+	importJobs      = make(map[string]*importJob)
+	importJobsMutex sync.Mutex
+)
+
+// importJob - state of running import with its cancel function
+type importJob struct {
+	mutex  sync.Mutex
+	state  models.OPMLImportJob
+	cancel context.CancelFunc
+}
+
+// opmlItem - feed outline with name of category
+type opmlItem struct {
+	Outline  models.OPMLOutline
+	Category string
+}
+
+// importCategories - ids of categories used by import job by name
+type importCategories struct {
+	mutex sync.Mutex
+	ids   map[string]int64
+}
+
+// ImportService - background OPML import, every feed is checked before subscription
+type ImportService struct {
+	db     *gorm.DB
+	config *models.Config
+}
+
+func NewImportService(config *models.Config) *ImportService {
+	return &ImportService{
+		db:     getDb(),
+		config: config,
+	}
+}
+
+func (service *ImportService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// Start - parse OPML file and import its feeds in background
+func (service *ImportService) Start(data []byte, userID int64) (*models.OPMLImportJob, error) {
+	opml, err := parseOPML(data)
+	if err != nil {
+		log.Println("OPML import error: ", err.Error())
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	items := opmlItems(opml.Outlines, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &importJob{
+		cancel: cancel,
+		state: models.OPMLImportJob{
+			Id:               id,
+			UserId:           userID,
+			Status:           JobRunning,
+			Total:            len(items),
+			Started:          time.Now().Unix(),
+			OPMLImportReport: models.OPMLImportReport{Feeds: make([]models.OPMLImportFeed, 0, len(items))},
+		},
+	}
+
+	importJobsMutex.Lock()
+	removeOldJobs()
+	importJobs[id] = job
+	importJobsMutex.Unlock()
+
+	go service.run(ctx, job, items)
+
+	return job.snapshot(), nil
+}
+
+// Get - get state of user import job, nil if job is not found
+func (service *ImportService) Get(id string, userID int64) *models.OPMLImportJob {
+	job := findJob(id, userID)
+	if job == nil {
+		return nil
+	}
+
+	return job.snapshot()
+}
+
+// Cancel - stop import, already imported feeds are kept
+func (service *ImportService) Cancel(id string, userID int64) *models.OPMLImportJob {
+	job := findJob(id, userID)
+	if job == nil {
+		return nil
+	}
+
+	job.cancel()
+
+	return job.snapshot()
+}
+
+// run - import feeds by several workers, duplicates in file are skipped
+func (service *ImportService) run(ctx context.Context, job *importJob, items []opmlItem) {
+	userID := job.state.UserId
+	categories := &importCategories{ids: make(map[string]int64)}
+
+	workers := service.config.UpdateWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan opmlItem)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for item := range queue {
+				job.add(service.importFeed(item, categories, userID))
+			}
+		}()
+	}
+
+	found := make(map[string]bool)
+
+loop:
+	for _, item := range items {
+		link := strings.TrimSpace(item.Outline.URL)
+		if found[link] {
+			job.add(models.OPMLImportFeed{
+				Url:      link,
+				Title:    strings.TrimSpace(item.Outline.Text),
+				Category: item.Category,
+				Status:   ImportSkipped,
+				Error:    "duplicate feed in file",
+			})
+			continue
+		}
+
+		found[link] = true
+
+		select {
+		case queue <- item:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	job.finish(ctx.Err() != nil)
+}
+
+// importFeed - check feed from OPML outline and subscribe it
+func (service *ImportService) importFeed(item opmlItem, categories *importCategories, userID int64) models.OPMLImportFeed {
+	result := models.OPMLImportFeed{
+		Url:      strings.TrimSpace(item.Outline.URL),
+		Title:    strings.TrimSpace(item.Outline.Title),
+		Category: item.Category,
+		Status:   ImportFailed,
+	}
+
+	if result.Title == "" {
+		result.Title = strings.TrimSpace(item.Outline.Text)
+	}
+
+	link, err := url.Parse(result.Url)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		result.Error = "invalid feed url"
+		return result
+	}
+
+	var count int64
+	service.db.Model(&models.Feeds{}).Where(&models.Feeds{UserId: userID, Url: result.Url}).Count(&count)
+
+	if count > 0 {
+		result.Status = ImportSkipped
+		result.Error = "feed is already subscribed"
+		return result
+	}
+
+	parsed, err := fetchFeed(result.Url)
+	if err != nil {
+		result.Error = newFeedError(err).Message
+		return result
+	}
+
+	if result.Title == "" {
+		result.Title = parsed.Title
+	}
+
+	siteURL := strings.TrimSpace(item.Outline.HTMLURL)
+	if siteURL == "" {
+		siteURL = parsed.Link
+	}
+
+	categoryID, err := service.importCategory(categories, item.Category, userID)
+	if err != nil {
+		log.Printf("OPML import category %s error: %s", item.Category, err)
+	}
+
+	feed := models.Feeds{
+		Name:       result.Title,
+		Url:        result.Url,
+		SiteUrl:    siteURL,
+		UserId:     userID,
+		CategoryId: categoryID,
+	}
+	if err := service.db.Create(&feed).Error; err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = ImportAdded
+
+	return result
+}
+
+// importCategory - get id of category by name, category is created when user has no such category.
+// Categories are created for imported feeds only, workers do not create the same category twice
+func (service *ImportService) importCategory(categories *importCategories, name string, userID int64) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	categories.mutex.Lock()
+	defer categories.mutex.Unlock()
+
+	if id, ok := categories.ids[name]; ok {
+		return id, nil
+	}
+
+	category := models.Categories{}
+	service.db.Where("UserId = ? and lower(Name) = lower(?)", userID, name).Limit(1).Find(&category)
+
+	if category.Id == 0 {
+		category = models.Categories{UserId: userID, Name: name}
+		if err := service.db.Create(&category).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	categories.ids[name] = category.Id
+
+	return category.Id, nil
+}
+
+func (job *importJob) add(feed models.OPMLImportFeed) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	switch feed.Status {
+	case ImportAdded:
+		job.state.Added++
+	case ImportSkipped:
+		job.state.Skipped++
+	default:
+		job.state.Failed++
+	}
+
+	job.state.Processed++
+	job.state.Feeds = append(job.state.Feeds, feed)
+}
+
+func (job *importJob) finish(cancelled bool) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	job.state.Status = JobDone
+	if cancelled {
+		job.state.Status = JobCancelled
+	}
+
+	job.state.Finished = time.Now().Unix()
+}
+
+// snapshot - copy of job state which is safe to encode
+func (job *importJob) snapshot() *models.OPMLImportJob {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+
+	state := job.state
+	state.Feeds = append([]models.OPMLImportFeed{}, job.state.Feeds...)
+
+	return &state
+}
+
+func findJob(id string, userID int64) *importJob {
+	importJobsMutex.Lock()
+	defer importJobsMutex.Unlock()
+
+	job, ok := importJobs[id]
+	if !ok || job.state.UserId != userID {
+		return nil
+	}
+
+	return job
+}
+
+// removeOldJobs - forget jobs finished long ago, jobs mutex must be locked
+func removeOldJobs() {
+	expired := time.Now().Add(-importJobLifetime).Unix()
+
+	for id, job := range importJobs {
+		job.mutex.Lock()
+		finished := job.state.Finished
+		job.mutex.Unlock()
+
+		if finished != 0 && finished < expired {
+			delete(importJobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// parseOPML - decode OPML file in any charset
+func parseOPML(data []byte) (*models.OPML, error) {
+	var opml models.OPML
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	if err := decoder.Decode(&opml); err != nil {
+		return nil, err
+	}
+
+	return &opml, nil
+}
+
+// opmlItems - get feeds from outlines tree, category of feed is its nearest parent outline without url
+func opmlItems(outlines []models.OPMLOutline, category string) []opmlItem {
+	items := make([]opmlItem, 0, len(outlines))
+
+	for _, outline := range outlines {
+		if strings.TrimSpace(outline.URL) != "" {
+			items = append(items, opmlItem{Outline: outline, Category: category})
+			continue
+		}
+
+		name := strings.TrimSpace(outline.Title)
+		if name == "" {
+			name = strings.TrimSpace(outline.Text)
+		}
+
+		items = append(items, opmlItems(outline.Outlines, name)...)
+	}
+
+	return items
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"newshub-server/models"
+)
+
+// waitImport - wait until import job is finished
+func waitImport(t *testing.T, service *ImportService, job *models.OPMLImportJob) *models.OPMLImportJob {
+	t.Helper()
+
+	for i := 0; i < 500; i++ {
+		state := service.Get(job.Id, job.UserId)
+		if state.Status != JobRunning {
+			return state
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("import is not finished")
+
+	return nil
+}
+
+func TestImportCategories(t *testing.T) {
+	config := setupTestDb(t)
+	config.UpdateWorkers = 4
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write(testRss("1"))
+	}))
+	defer server.Close()
+
+	sharedFetcherOnce.Do(func() {})
+	sharedFetcher = newTestFetcher("127.0.0.1")
+
+	// user already has category with other case of name
+	db.Create(&models.Categories{UserId: 1, Name: "Blogs"})
+
+	opml := `<?xml version="1.0"?><opml version="2.0"><body>
+		<outline text="News">
+			<outline text="a" xmlUrl="` + server.URL + `/a"/>
+			<outline text="b" xmlUrl="` + server.URL + `/b"/>
+			<outline text="c" xmlUrl="` + server.URL + `/c"/>
+			<outline text="d" xmlUrl="` + server.URL + `/d"/>
+		</outline>
+		<outline text="blogs">
+			<outline text="e" xmlUrl="` + server.URL + `/e"/>
+		</outline>
+		<outline text="Broken">
+			<outline text="f" xmlUrl="` + server.URL + `/missing/f"/>
+			<outline text="g" xmlUrl="` + server.URL + `/missing/g"/>
+		</outline>
+		<outline text="Invalid">
+			<outline text="h" xmlUrl="ftp://example.com/h"/>
+		</outline>
+	</body></opml>`
+
+	service := NewImportService(config)
+
+	job, err := service.Start([]byte(opml), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := waitImport(t, service, job)
+	if state.Added != 5 || state.Failed != 3 {
+		t.Errorf("%d feeds are added and %d failed, want 5 and 3", state.Added, state.Failed)
+	}
+
+	var categories []models.Categories
+	db.Where(&models.Categories{UserId: 1}).Order("Id").Find(&categories)
+
+	// categories without imported feeds are not created
+	if len(categories) != 2 || categories[0].Name != "Blogs" || categories[1].Name != "News" {
+		t.Fatalf("categories %+v, want Blogs and News", categories)
+	}
+
+	tests := []struct {
+		category int64
+		count    int64
+	}{
+		{categories[0].Id, 1},
+		{categories[1].Id, 4},
+	}
+
+	for _, test := range tests {
+		var count int64
+		db.Model(&models.Feeds{}).Where(&models.Feeds{UserId: 1, CategoryId: test.category}).Count(&count)
+
+		if count != test.count {
+			t.Errorf("category %d has %d feeds, want %d", test.category, count, test.count)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// retentionInterval - how often old items are removed
+const retentionInterval = time.Hour
+
+// purgedBatchSize - count of guids of removed articles inserted by one query
+const purgedBatchSize = 100
+
+// twitterEpoch - start of tweet id timestamps in milliseconds
+const twitterEpoch = 1288834974657
+
+// RetentionService - removes old read articles, vk posts and tweets.
+// Limits are taken from config, user settings and feed, 0 means "use upper level", -1 - keep all
+type RetentionService struct {
+	db     *gorm.DB
+	config *models.Config
+	stop   chan struct{}
+}
+
+// NewRetentionService - create service, default limits are taken from config
+func NewRetentionService(config *models.Config) *RetentionService {
+	return &RetentionService{
+		db:     getDb(),
+		config: config,
+		stop:   make(chan struct{}),
+	}
+}
+
+func (service *RetentionService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// Start - run retention loop in background
+func (service *RetentionService) Start() {
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+
+		service.PurgeAll()
+
+		for {
+			select {
+			case <-ticker.C:
+				service.PurgeAll()
+			case <-service.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stop retention loop
+func (service *RetentionService) Stop() {
+	close(service.stop)
+}
+
+// PurgeAll - apply retention policy for all users
+func (service *RetentionService) PurgeAll() {
+	var users []models.Users
+
+	if err := service.db.Select("Id").Find(&users).Error; err != nil {
+		log.Println("get users for retention error:", err)
+		return
+	}
+
+	for _, user := range users {
+		report, err := service.Purge(user.Id, false)
+		if err != nil {
+			log.Printf("retention for user %d error: %s", user.Id, err)
+			continue
+		}
+		if report.Articles+report.VkNews+report.TwitterNews > 0 {
+			log.Printf("retention for user %d removed articles: %d, vk news: %d, tweets: %d",
+				user.Id, report.Articles, report.VkNews, report.TwitterNews)
+		}
+	}
+}
+
+// Purge - remove old items of user, items are only counted in dry run
+func (service *RetentionService) Purge(userID int64, dryRun bool) (*models.RetentionReport, error) {
+	var settings models.Settings
+	var feeds []models.Feeds
+
+	service.db.Where(models.Settings{UserId: userID}).Find(&settings)
+
+	days := retentionLimit(retentionLimit(0, service.config.RetentionDays), settings.RetentionDays)
+	count := retentionLimit(retentionLimit(0, service.config.RetentionCount), settings.RetentionCount)
+	report := &models.RetentionReport{DryRun: dryRun, Feeds: make([]models.RetentionFeedReport, 0)}
+
+	if err := service.db.Where(&models.Feeds{UserId: userID}).Order("Id").Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+
+	for _, feed := range feeds {
+		feedReport := models.RetentionFeedReport{
+			FeedId: feed.Id,
+			Name:   feed.Name,
+			Days:   retentionLimit(days, feed.RetentionDays),
+			Count:  retentionLimit(count, feed.RetentionCount),
+		}
+		if feedReport.Days == 0 && feedReport.Count == 0 {
+			continue
+		}
+
+		removed, err := service.purgeArticles(feed.Id, retentionCutoff(feedReport.Days), feedReport.Count, dryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		feedReport.Articles = removed
+		report.Articles += removed
+		report.Feeds = append(report.Feeds, feedReport)
+	}
+
+	if days == 0 && count == 0 {
+		return report, nil
+	}
+
+	var err error
+
+	cutoff := retentionCutoff(days)
+	report.VkNews, err = service.purgeSources(&models.VkNews{}, "GroupId", "Timestamp", userID, cutoff, count, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// tweets have no date, it is a part of tweet id
+	tweetCutoff := int64(0)
+	if cutoff > 0 {
+		tweetCutoff = (cutoff*1000 - twitterEpoch) << 22
+	}
+	report.TwitterNews, err = service.purgeSources(&models.TwitterNews{}, "SourceId", "TweetId", userID, tweetCutoff, count, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// purgeArticles - remove read articles of feed, bookmarks are kept
+func (service *RetentionService) purgeArticles(feedID int64, cutoff int64, count int, dryRun bool) (int64, error) {
+	source := service.db.Model(&models.Articles{}).Where(&models.Articles{FeedId: feedID})
+	condition, args, err := retentionCondition(source, "Date", cutoff, count)
+	if err != nil || condition == "" {
+		return 0, err
+	}
+
+	removable := func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.Articles{}).
+			Where(&models.Articles{FeedId: feedID, IsRead: true}).
+			Not(&models.Articles{IsBookmark: true}).
+			Where(condition, args...)
+	}
+
+	var removed int64
+
+	if dryRun {
+		err := removable(service.db).Count(&removed).Error
+		return removed, err
+	}
+
+	err = service.db.Transaction(func(tx *gorm.DB) error {
+		// guids of removed articles are kept, so items which are still in feed are not added again as unread
+		var purged []models.PurgedArticles
+		if err := removable(tx).Select("FeedId, Guid").Scan(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+
+		now := time.Now().Unix()
+		for i := range purged {
+			purged[i].Date = now
+		}
+
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&purged, purgedBatchSize).Error
+		if err != nil {
+			return err
+		}
+
+		articleIds := removable(tx).Select("Id")
+		enclosureIds := tx.Model(&models.Enclosures{}).Select("Id").Where("ArticleId IN (?)", articleIds)
+
+		if err := tx.Where("EnclosureId IN (?)", enclosureIds).Delete(models.PlaybackPositions{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ArticleId IN (?)", articleIds).Delete(models.Enclosures{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ArticleId IN (?)", articleIds).Delete(models.ArticleTags{}).Error; err != nil {
+			return err
+		}
+
+		result := removable(tx).Delete(models.Articles{})
+		removed = result.RowsAffected
+
+		return result.Error
+	})
+
+	return removed, err
+}
+
+// purgeSources - remove items of user table, count limit is applied to every source
+func (service *RetentionService) purgeSources(model interface{}, sourceColumn, dateColumn string, userID, cutoff int64, count int, dryRun bool) (int64, error) {
+	// without count limit all sources are processed by one query
+	sources := []int64{0}
+
+	if count > 0 {
+		sources = nil
+		err := service.db.Model(model).Where("UserId = ?", userID).Distinct().Pluck(sourceColumn, &sources).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var total int64
+
+	for _, sourceID := range sources {
+		items := func(db *gorm.DB) *gorm.DB {
+			query := db.Model(model).Where("UserId = ?", userID)
+			if sourceID != 0 {
+				query = query.Where(sourceColumn+" = ?", sourceID)
+			}
+
+			return query
+		}
+
+		condition, args, err := retentionCondition(items(service.db), dateColumn, cutoff, count)
+		if err != nil {
+			return total, err
+		}
+		if condition == "" {
+			continue
+		}
+
+		var removed int64
+
+		if dryRun {
+			err = items(service.db).Where(condition, args...).Count(&removed).Error
+		} else {
+			result := items(service.db).Where(condition, args...).Delete(model)
+			removed, err = result.RowsAffected, result.Error
+		}
+		if err != nil {
+			return total, err
+		}
+
+		total += removed
+	}
+
+	return total, nil
+}
+
+// retentionCondition - sql condition for items older than cutoff or beyond count newest items of source
+func retentionCondition(source *gorm.DB, column string, cutoff int64, count int) (string, []interface{}, error) {
+	conditions := make([]string, 0, 2)
+	args := make([]interface{}, 0, 4)
+
+	if cutoff > 0 {
+		conditions = append(conditions, column+" < ?")
+		args = append(args, cutoff)
+	}
+
+	if count > 0 {
+		var boundary struct {
+			Id    int64
+			Value int64
+		}
+
+		err := source.Select("Id, " + column + " as Value").
+			Order(column + " desc, Id desc").
+			Offset(count).
+			Limit(1).
+			Scan(&boundary).
+			Error
+		if err != nil {
+			return "", nil, err
+		}
+
+		if boundary.Id != 0 {
+			conditions = append(conditions, "("+column+" < ? or ("+column+" = ? and Id <= ?))")
+			args = append(args, boundary.Value, boundary.Value, boundary.Id)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+
+	return "(" + strings.Join(conditions, " or ") + ")", args, nil
+}
+
+// retentionLimit - apply override to limit of upper level
+func retentionLimit(limit int, override int) int {
+	switch {
+	case override > 0:
+		return override
+	case override < 0:
+		return 0
+	default:
+		return limit
+	}
+}
+
+func retentionCutoff(days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+
+	return time.Now().AddDate(0, 0, -days).Unix()
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"newshub-server/models"
+)
+
+func TestPurgedArticlesAreNotAddedAgain(t *testing.T) {
+	now := time.Now()
+	old := now.AddDate(0, 0, -10).Format(time.RFC1123Z)
+	items := []models.ParsedItem{
+		{Guid: "1", Title: "first", Link: "https://example.com/1", Date: now.Add(-3 * time.Hour).Format(time.RFC1123Z)},
+		{Guid: "2", Title: "second", Link: "https://example.com/2", Date: old},
+		{Guid: "3", Title: "third", Link: "https://example.com/3", Date: now.Add(-time.Hour).Format(time.RFC1123Z)},
+	}
+
+	tests := []struct {
+		name   string
+		days   int
+		count  int
+		purged int64
+		kept   []string
+	}{
+		{"count", 0, 1, 2, []string{"3"}},
+		{"days", 5, 0, 1, []string{"1", "3"}},
+		{"days and count", 5, 2, 1, []string{"1", "3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := setupTestDb(t)
+			feed := models.Feeds{UserId: 1, Name: "feed", Url: "https://example.com/feed", RetentionDays: test.days, RetentionCount: test.count}
+			db.Create(&feed)
+
+			updater := NewUpdateService(config)
+			if _, err := updater.saveArticles(feed, append([]models.ParsedItem{}, items...)); err != nil {
+				t.Fatal(err)
+			}
+
+			db.Model(&models.Articles{}).Where(&models.Articles{FeedId: feed.Id}).UpdateColumn("IsRead", true)
+
+			report, err := NewRetentionService(config).Purge(feed.UserId, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Articles != test.purged {
+				t.Fatalf("purged %d articles, want %d", report.Articles, test.purged)
+			}
+
+			created, err := updater.saveArticles(feed, append([]models.ParsedItem{}, items...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(created) != 0 {
+				t.Errorf("%d purged articles are added again", len(created))
+			}
+
+			var guids []string
+			db.Model(&models.Articles{}).Where(&models.Articles{FeedId: feed.Id}).Order("Guid").Pluck("Guid", &guids)
+
+			if len(guids) != len(test.kept) {
+				t.Fatalf("articles %v, want %v", guids, test.kept)
+			}
+			for i := range guids {
+				if guids[i] != test.kept[i] {
+					t.Fatalf("articles %v, want %v", guids, test.kept)
+				}
+			}
+		})
+	}
+}
+
+func TestPrunePurged(t *testing.T) {
+	config := setupTestDb(t)
+	feed := models.Feeds{UserId: 1, Name: "feed", Url: "https://example.com/feed"}
+	db.Create(&feed)
+	db.Create(&[]models.PurgedArticles{{FeedId: feed.Id, Guid: "1"}, {FeedId: feed.Id, Guid: "2"}, {FeedId: feed.Id + 1, Guid: "3"}})
+
+	updater := NewUpdateService(config)
+
+	// empty fetch result keeps all guids
+	updater.prunePurged(feed, nil)
+	// item 1 is still in feed, item 2 is removed from feed
+	updater.prunePurged(feed, []models.ParsedItem{{Guid: "1"}, {Guid: "4"}})
+
+	var guids []string
+	db.Model(&models.PurgedArticles{}).Order("Guid").Pluck("Guid", &guids)
+
+	if len(guids) != 2 || guids[0] != "1" || guids[1] != "3" {
+		t.Errorf("purged guids %v, want [1 3]", guids)
+	}
+
+	created, err := updater.saveArticles(feed, []models.ParsedItem{{Guid: "1", Title: "first"}, {Guid: "2", Title: "second"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Guid != "2" {
+		t.Errorf("created %v, want only article 2", created)
+	}
+}
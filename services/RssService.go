@@ -1,16 +1,21 @@
 package services
 
 import (
-	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"newshub-server/models"
 
-	"golang.org/x/net/html/charset"
 	"gorm.io/gorm"
 )
 
@@ -70,10 +75,11 @@ func (service *RssService) GetArticles(id int64, userID int64, page int) *models
 	whereObject := models.Articles{FeedId: id}
 
 	query := service.db.Where(&whereObject).
-		Select("Id, Title, IsBookmark, IsRead, Link, FeedId").
+		Select("Id, Title, IsBookmark, IsRead, Link, FeedId, Date").
+		Preload("Enclosures").
 		Limit(service.config.PageSize).
 		Offset(offset).
-		Order("Id desc")
+		Order("Date desc, Id desc")
 	queryCount := service.db.Model(&whereObject).Where(&whereObject)
 
 	var settings models.Settings
@@ -88,6 +94,8 @@ func (service *RssService) GetArticles(id int64, userID int64, page int) *models
 	query.Find(&articles)
 	queryCount.Count(&count)
 
+	attachTags(service.db, articles)
+
 	return &models.ArticlesJSON{Articles: articles, Count: count}
 }
 
@@ -103,115 +111,180 @@ func (service *RssService) GetArticle(id int64, feedID int64, userID int64) *mod
 	var article models.Articles
 	service.db.Where(&models.Articles{Id: id, FeedId: feedID}).First(&article)
 
-	var settings models.Settings // todo: to func
-	service.db.Where(models.Settings{UserId: userID}).Find(&settings)
+	if article.Id == 0 {
+		return nil
+	}
 
 	// update state
+	wasRead := article.IsRead
 	article.IsRead = true
-	service.db.Save(&article)
 
-	if settings.MarkSameRead {
-		go service.markSameArticles(article.Link, article.FeedId)
+	if err := service.saveArticle(&article, wasRead, userID); err != nil {
+		log.Println("update article error:", err)
 	}
 
-	return &article
-}
-
-// Import - import OPML file
-func (service *RssService) Import(data []byte, userID int64) {
-	// parse opml
-	var opml models.OPML
-	decoder := xml.NewDecoder(bytes.NewReader(data))
-	decoder.CharsetReader = charset.NewReaderLabel
-	err := decoder.Decode(&opml)
-
-	if err != nil {
-		log.Println("OPML import error: ", err.Error())
-		return
+	// content of articles saved before full content mode is extracted in background
+	for _, feed := range rss {
+		if feed.Feed.Id == feedID && feed.Feed.FullContent && article.FullBody == "" {
+			saveFullContentAsync(service.db, article)
+		}
 	}
 
-	dbExec(func(db *gorm.DB) {
-		for _, outline := range opml.Outlines {
-			feed := models.Feeds{
-				Name:   outline.Title,
-				Url:    outline.URL,
-				UserId: userID,
-			}
-			db.Save(&feed)
-		}
-	})
+	article.Body = sanitizeHTML(article.Body, article.Link)
+	article.FullBody = sanitizeHTML(article.FullBody, article.Link)
+	service.db.Where(&models.Enclosures{ArticleId: article.Id}).Find(&article.Enclosures)
+	article.Tags = articleTags(service.db, []int64{article.Id})[article.Id]
+
+	return &article
 }
 
-// Export - export feeds to OPML file
+// Export - export feeds to OPML file, feeds of categories are nested in category outlines
 func (service *RssService) Export(userID int64) []byte {
 	// get data from DB
 	var rss []models.Feeds
-	service.db.Where(&models.Feeds{UserId: userID}).Find(&rss)
+	var categories []models.Categories
+	service.db.Where(&models.Feeds{UserId: userID}).Order("Name").Find(&rss)
+	service.db.Where(&models.Categories{UserId: userID}).Order("Name").Find(&categories)
+
 	opml := models.OPML{
-		HeadText: "Feeds",
-		Version:  1.1,
-		Outlines: make([]models.OPMLOutline, 0, len(rss)),
+		Version:     "2.0",
+		Title:       "Feeds",
+		DateCreated: time.Now().Format(time.RFC1123Z),
+		Outlines:    make([]models.OPMLOutline, 0, len(categories)+len(rss)),
+	}
+
+	// index of category outline
+	folders := make(map[int64]int, len(categories))
+
+	for _, category := range categories {
+		folders[category.Id] = len(opml.Outlines)
+		opml.Outlines = append(opml.Outlines, models.OPMLOutline{Text: category.Name, Title: category.Name})
 	}
 
-	// create array of structures
 	for _, feed := range rss {
 		outline := models.OPMLOutline{
-			Title: feed.Name,
-			URL:   feed.Url,
-			Text:  feed.Name,
+			Text:    feed.Name,
+			Title:   feed.Name,
+			Type:    "rss",
+			URL:     feed.Url,
+			HTMLURL: feed.SiteUrl,
+		}
+
+		if index, ok := folders[feed.CategoryId]; ok {
+			opml.Outlines[index].Outlines = append(opml.Outlines[index].Outlines, outline)
+		} else {
+			opml.Outlines = append(opml.Outlines, outline)
 		}
-		opml.Outlines = append(opml.Outlines, outline)
 	}
 
 	// create OPML file bytes
-	xmlString, _ := xml.Marshal(opml)
-	fmt.Println(string(xmlString))
+	xmlString, _ := xml.MarshalIndent(opml, "", "  ")
 
-	return xmlString
+	return append([]byte(xml.Header), xmlString...)
 }
 
-// AddFeed - add new feed
-func (service *RssService) AddFeed(url string, userID int64) {
-	// get rss xml
-	response, err := http.Get(url)
+// AddFeed - add new feed, for web pages feeds are discovered and
+// list of candidates is returned when page has more than one feed
+func (service *RssService) AddFeed(url string, userID int64) ([]models.FeedCandidate, error) {
+	feedURL, parsed, candidates, err := discoverFeed(url)
 
 	if err != nil {
-		log.Println("Get XML error: ", err.Error())
-		return
+		log.Println("get feed error on URL: ", url, err.Error())
+		return nil, newFeedError(err)
 	}
-
-	defer response.Body.Close()
-
-	// parse feed xml and create structure
-	var xmlModel models.XMLFeed
-	decoder := xml.NewDecoder(response.Body)
-	decoder.CharsetReader = charset.NewReaderLabel
-	err = decoder.Decode(&xmlModel)
-
-	if err != nil {
-		log.Println("XML unmarshall error on URL: ", url, err.Error())
-		return
+	if parsed == nil {
+		return candidates, nil
 	}
 
 	// insert in DB
-	err = service.db.Create(&models.Feeds{Url: url, UserId: userID, Name: xmlModel.RssName}).Error
+	err = service.db.Create(&models.Feeds{Url: feedURL, SiteUrl: parsed.Link, UserId: userID, Name: parsed.Title}).Error
 	// todo: send message for update
 
 	if err != nil {
 		log.Println("insert error", err.Error())
+		return nil, err
 	}
+
+	return nil, nil
+}
+
+// previewItems - count of latest items in feed preview
+const previewItems = 10
+
+// Preview - get feed content without subscription, feed is searched the same way as in AddFeed
+func (service *RssService) Preview(url string) (*models.FeedPreview, error) {
+	feedURL, parsed, candidates, err := discoverFeed(url)
+
+	if err != nil {
+		return nil, newFeedError(err)
+	}
+	if parsed == nil {
+		return &models.FeedPreview{Url: url, Items: []models.Articles{}, Candidates: candidates}, nil
+	}
+
+	base := parsed.Link
+	if base == "" {
+		base = feedURL
+	}
+
+	normalizeItems(parsed.Items, base)
+
+	now := time.Now()
+	items := make([]models.Articles, 0, len(parsed.Items))
+
+	for _, item := range parsed.Items {
+		items = append(items, models.Articles{
+			Guid:  itemGuid(item),
+			Title: item.Title,
+			Body:  item.Body,
+			Link:  item.Link,
+			Date:  parseDate(item.Date, now).Unix(),
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date > items[j].Date
+	})
+
+	if len(items) > previewItems {
+		items = items[:previewItems]
+	}
+
+	return &models.FeedPreview{
+		Url:         feedURL,
+		Format:      parsed.Format,
+		Title:       parsed.Title,
+		Description: parsed.Description,
+		Link:        parsed.Link,
+		ItemCount:   len(parsed.Items),
+		Items:       items,
+		Candidates:  []models.FeedCandidate{},
+	}, nil
 }
 
 // Delete - remove feed
 func (service *RssService) Delete(id int64, userID int64) {
-	feed := service.GetRss(userID)
+	deleted := models.Feeds{}
+	service.db.Where(&models.Feeds{Id: id, UserId: userID}).First(&deleted)
 
-	if len(feed) == 0 {
+	// feed of another user is not deleted
+	if deleted.Id == 0 {
 		return
 	}
 
+	articleIds := service.db.Model(&models.Articles{}).Select("Id").Where(&models.Articles{FeedId: id})
+	enclosureIds := service.db.Model(&models.Enclosures{}).Select("Id").Where("ArticleId IN (?)", articleIds)
+
+	service.db.Where("EnclosureId IN (?)", enclosureIds).Delete(models.PlaybackPositions{})
+	service.db.Where("ArticleId IN (?)", articleIds).Delete(models.Enclosures{})
+	service.db.Where("ArticleId IN (?)", articleIds).Delete(models.ArticleTags{})
 	service.db.Where(models.Articles{FeedId: id}).Delete(models.Articles{})
+	service.db.Where(models.PurgedArticles{FeedId: id}).Delete(models.PurgedArticles{})
 	service.db.Delete(models.Feeds{Id: id})
+
+	if deleted.HubSecret != "" || deleted.HubNewSecret != "" {
+		go unsubscribeHub(service.config, deleted)
+	}
 }
 
 // SetNewName - update feed name
@@ -227,16 +300,80 @@ func (service *RssService) SetNewName(data models.FeedUpdateData, userID int64)
 		service.db.Model(&models.Articles{}).
 			Where(&models.Articles{FeedId: feed.Id}).
 			Not(&models.Articles{IsRead: true}).
-			UpdateColumn("is_read = ?", true)
+			UpdateColumn("IsRead", true)
+	}
+	if data.Enable && feed.IsPaused {
+		feed.IsPaused = false
+		feed.FailCount = 0
+		// fetch feed on next update without waiting for backoff of failures
+		feed.NextFetch = time.Now().Unix()
+		service.db.Save(&feed)
 	}
 	if data.Name != "" {
 		feed.Name = data.Name
 		service.db.Save(&feed)
 	}
+	if data.FullContent != nil {
+		feed.FullContent = *data.FullContent
+		service.db.Save(&feed)
+	}
+	if data.UpdateMinutes != 0 {
+		feed.UpdateMinutes = data.UpdateMinutes
+		if feed.UpdateMinutes < 0 {
+			feed.UpdateMinutes = 0
+		}
+
+		// apply new interval without waiting for previous one
+		if next := feed.LastFetch + int64(feed.UpdateMinutes)*60; feed.UpdateMinutes > 0 && next < feed.NextFetch {
+			feed.NextFetch = next
+		}
+
+		service.db.Save(&feed)
+	}
+	if data.CategoryId != nil {
+		category := models.Categories{}
+		if *data.CategoryId != 0 {
+			service.db.Where(&models.Categories{Id: *data.CategoryId, UserId: userID}).Find(&category)
+		}
+
+		// unknown category removes feed from category
+		feed.CategoryId = category.Id
+		service.db.Save(&feed)
+	}
+	if data.RetentionDays != nil || data.RetentionCount != nil {
+		if data.RetentionDays != nil {
+			feed.RetentionDays = *data.RetentionDays
+		}
+		if data.RetentionCount != nil {
+			feed.RetentionCount = *data.RetentionCount
+		}
+
+		service.db.Save(&feed)
+	}
 
 	return feed
 }
 
+// GetStatus - get feed update health
+func (service *RssService) GetStatus(id int64, userID int64) *models.FeedStatus {
+	feed := models.Feeds{}
+	service.db.Where(&models.Feeds{Id: id, UserId: userID}).First(&feed)
+
+	if feed.Id == 0 {
+		return nil
+	}
+
+	return &models.FeedStatus{
+		FeedId:      feed.Id,
+		LastFetch:   feed.LastFetch,
+		LastSuccess: feed.LastSuccess,
+		LastError:   feed.LastError,
+		HttpStatus:  feed.HttpStatus,
+		FailCount:   feed.FailCount,
+		IsPaused:    feed.IsPaused,
+	}
+}
+
 // GetBookmarks - get all bookmarks
 func (service *RssService) GetBookmarks(page int, userID int64) *models.ArticlesJSON {
 	var articles []models.Articles
@@ -246,39 +383,91 @@ func (service *RssService) GetBookmarks(page int, userID int64) *models.Articles
 
 	service.db.Where(whereCond, userID).
 		Joins("join feeds on articles.FeedId = feeds.Id").
-		Select("Id, Title, IsBookmark, IsRead").
+		Select("articles.Id, articles.Title, articles.IsBookmark, articles.IsRead, articles.Link, articles.FeedId, articles.Date").
+		Preload("Enclosures").
 		Limit(service.config.PageSize).
 		Offset(offset).
-		Order("Id desc").
+		Order("articles.Date desc, articles.Id desc").
 		Find(&articles)
 	service.db.Model(&models.Articles{}).Where(whereCond, userID).
 		Joins("join feeds on articles.FeedId = feeds.Id").Count(&count)
 
+	attachTags(service.db, articles)
+
 	return &models.ArticlesJSON{Articles: articles, Count: count}
 }
 
+// Scopes of bulk mark as read
+const (
+	MarkReadAll      = "all"
+	MarkReadFeed     = "feed"
+	MarkReadCategory = "category"
+	MarkReadSearch   = "search"
+)
+
+// MarkRead - mark unread articles of scope as read, count of changed articles is returned
+func (service *RssService) MarkRead(data models.MarkReadData, userID int64) (int64, error) {
+	feedIds := service.db.Model(&models.Feeds{}).Select("Id").Where(&models.Feeds{UserId: userID})
+	query := service.db.Model(&models.Articles{})
+
+	switch data.Scope {
+	case MarkReadAll:
+	case MarkReadFeed:
+		feedIds = feedIds.Where("Id = ?", data.FeedId)
+	case MarkReadCategory:
+		feedIds = feedIds.Where("CategoryId = ? and CategoryId <> 0", data.CategoryId)
+	case MarkReadSearch:
+		if data.Search == "" {
+			return 0, errors.New("search string is empty")
+		}
+
+		query = query.Where("(Title LIKE ? OR Body LIKE ?)", "%"+data.Search+"%", "%"+data.Search+"%")
+	default:
+		return 0, fmt.Errorf("unknown scope: %s", data.Scope)
+	}
+
+	if data.OlderThan > 0 {
+		query = query.Where("Date < ?", data.OlderThan)
+	}
+
+	result := query.Where("FeedId IN (?)", feedIds).
+		Not(&models.Articles{IsRead: true}).
+		UpdateColumn("IsRead", true)
+
+	return result.RowsAffected, result.Error
+}
+
 // Search - search articles by title or body
 func (service *RssService) Search(searchString string, isBookmark bool, feedID int64, userID int64) *models.ArticlesJSON {
 	var articles []models.Articles
-	query := service.db.
+
+	searchQuery(service.db, searchString, isBookmark, feedID, userID).
+		Select("articles.Id, articles.Title, articles.IsBookmark, articles.IsRead, articles.Link, articles.Date").
+		Order("articles.Date desc").
+		Find(&articles)
+
+	attachTags(service.db, articles)
+
+	return &models.ArticlesJSON{Articles: articles}
+}
+
+// searchQuery - articles of user with search string in title or body
+func searchQuery(db *gorm.DB, searchString string, isBookmark bool, feedID int64, userID int64) *gorm.DB {
+	query := db.Model(&models.Articles{}).
 		Joins("join feeds on articles.FeedId = feeds.Id").
-		Select("articles.Id, articles.Title, articles.IsBookmark, articles.IsRead, articles.Link").
 		Where("(articles.Title LIKE ? OR articles.Body LIKE ?) and feeds.UserId = ?", "%"+searchString+"%", "%"+searchString+"%", userID)
 
 	if feedID != 0 {
-		query = query.Where(&models.Articles{Id: feedID})
+		query = query.Where("articles.FeedId = ?", feedID)
 	}
 	if isBookmark {
-		query = query.Where("articles.IsBookmark = 1")
+		query = query.Where("articles.IsBookmark = ?", true)
 	}
 
-	query.Find(&articles)
-
-	return &models.ArticlesJSON{Articles: articles}
+	return query
 }
 
 func (service *RssService) ArticleUpdate(userID int64, data models.ArticlesUpdateData) models.Articles {
-	service.db = service.db.Debug()
 	whereCond := "articles.Id = ? and feeds.UserId = ?"
 	article := models.Articles{}
 	err := service.db.
@@ -291,18 +480,357 @@ func (service *RssService) ArticleUpdate(userID int64, data models.ArticlesUpdat
 		return article
 	}
 
+	wasRead := article.IsRead
 	article.IsBookmark = data.IsBookmark
 	article.IsRead = data.IsRead
 
-	if err := service.db.Save(&article).Error; err != nil {
+	if err := service.saveArticle(&article, wasRead, userID); err != nil {
 		log.Println("update article error:", err)
 	}
 
+	article.Tags = articleTags(service.db, []int64{article.Id})[article.Id]
+
 	return article
 }
 
-func (service *RssService) markSameArticles(url string, feedID int64) {
-	// service.db.Model(&models.Articles{}).Where("Link = ? and FeedId != ?").
-	// 	Not(&models.Articles{Id: feedID}).
-	// 	UpdateColumn("IsRead = ?", true)
+// GetPlayback - get user playback position of enclosure
+func (service *RssService) GetPlayback(enclosureID int64, userID int64) *models.PlaybackPositions {
+	if !service.isUserEnclosure(enclosureID, userID) {
+		return nil
+	}
+
+	position := models.PlaybackPositions{UserId: userID, EnclosureId: enclosureID}
+	service.db.Where(&models.PlaybackPositions{UserId: userID, EnclosureId: enclosureID}).Find(&position)
+
+	return &position
+}
+
+// SavePlayback - save user playback position of enclosure
+func (service *RssService) SavePlayback(userID int64, data models.PlaybackUpdateData) *models.PlaybackPositions {
+	if !service.isUserEnclosure(data.EnclosureId, userID) {
+		return nil
+	}
+
+	position := models.PlaybackPositions{}
+	service.db.Where(&models.PlaybackPositions{UserId: userID, EnclosureId: data.EnclosureId}).Find(&position)
+
+	position.UserId = userID
+	position.EnclosureId = data.EnclosureId
+	position.Position = data.Position
+	position.Updated = time.Now().Unix()
+
+	if err := service.db.Save(&position).Error; err != nil {
+		log.Println("save playback position error:", err)
+	}
+
+	return &position
+}
+
+func (service *RssService) isUserEnclosure(enclosureID int64, userID int64) bool {
+	var count int64
+
+	service.db.Model(&models.Enclosures{}).
+		Joins("join articles on enclosures.ArticleId = articles.Id").
+		Joins("join feeds on articles.FeedId = feeds.Id").
+		Where("enclosures.Id = ? and feeds.UserId = ?", enclosureID, userID).
+		Count(&count)
+
+	return count > 0
+}
+
+// Resanitize - sanitize bodies of all stored articles, count of changed articles is returned
+func (service *RssService) Resanitize() (int, error) {
+	var articles []models.Articles
+	changed := 0
+
+	err := service.db.FindInBatches(&articles, 500, func(tx *gorm.DB, batch int) error {
+		for _, article := range articles {
+			body := sanitizeHTML(article.Body, article.Link)
+			fullBody := sanitizeHTML(article.FullBody, article.Link)
+
+			if body == article.Body && fullBody == article.FullBody {
+				continue
+			}
+
+			err := service.db.Model(&article).UpdateColumns(map[string]interface{}{
+				"Body":     body,
+				"FullBody": fullBody,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			changed++
+		}
+
+		return nil
+	}).Error
+
+	return changed, err
+}
+
+// trackingParams - query parameters ignored when links of articles are compared, utm_* parameters are ignored too
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ref_src": true,
+}
+
+// saveArticle - save article state, when article becomes read articles with the same link
+// are marked as read in the same transaction if user enabled it in settings
+func (service *RssService) saveArticle(article *models.Articles, wasRead bool, userID int64) error {
+	var settings models.Settings
+	service.db.Where(models.Settings{UserId: userID}).Find(&settings)
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(article).Error; err != nil {
+			return err
+		}
+		if wasRead || !article.IsRead || !settings.MarkSameRead {
+			return nil
+		}
+
+		_, err := markSameArticles(tx, *article, userID, settings.MarkSameTitle)
+
+		return err
+	})
+}
+
+// markSameArticles - mark unread articles of all user feeds with the same normalized link as read,
+// titles must be equal too when sameTitle is set. Count of changed articles is returned
+func markSameArticles(tx *gorm.DB, article models.Articles, userID int64, sameTitle bool) (int64, error) {
+	key := normalizeLink(article.Link)
+	link, err := url.Parse(key)
+	if err != nil || key == "" {
+		return 0, err
+	}
+
+	var candidates []models.Articles
+	err = tx.Select("Id, Title, Link").
+		Where("FeedId IN (?)", tx.Model(&models.Feeds{}).Select("Id").Where(&models.Feeds{UserId: userID})).
+		Where("Id <> ? and lower(Link) LIKE ?", article.Id, "%"+strings.ToLower(link.Host+link.EscapedPath())+"%").
+		Not(&models.Articles{IsRead: true}).
+		Find(&candidates).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		if normalizeLink(candidate.Link) != key {
+			continue
+		}
+		if sameTitle && !strings.EqualFold(strings.TrimSpace(candidate.Title), strings.TrimSpace(article.Title)) {
+			continue
+		}
+
+		ids = append(ids, candidate.Id)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := tx.Model(&models.Articles{}).Where("Id IN (?)", ids).UpdateColumn("IsRead", true)
+
+	return result.RowsAffected, result.Error
+}
+
+// normalizeLink - link for comparison of articles: scheme, www prefix, default port, fragment,
+// tracking parameters and trailing slash are removed, query parameters are sorted
+func normalizeLink(link string) string {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		name := strings.ToLower(key)
+		if strings.HasPrefix(name, "utm_") || trackingParams[name] {
+			query.Del(key)
+		}
+	}
+
+	normalized := url.URL{
+		Scheme:   "http",
+		Host:     host,
+		Path:     strings.TrimRight(parsed.Path, "/"),
+		RawPath:  strings.TrimRight(parsed.RawPath, "/"),
+		RawQuery: query.Encode(),
+	}
+
+	return normalized.String()
+}
+
+// FetchError - unsuccessful HTTP response of feed server
+type FetchError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (err *FetchError) Error() string {
+	return "get feed error: status " + err.Message
+}
+
+// fetchResult - result of conditional feed request
+type fetchResult struct {
+	Feed         *models.ParsedFeed
+	StatusCode   int
+	MaxAge       time.Duration
+	Hub          string
+	Self         string
+	Data         []byte
+	ContentType  string
+	NotModified  bool
+	ETag         string
+	LastModified string
+}
+
+// fetchFeed - download and parse feed by url
+func fetchFeed(url string) (*models.ParsedFeed, error) {
+	result, err := fetchFeedIfModified(url, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Feed, nil
+}
+
+// fetchFeedIfModified - download and parse feed, validators from previous response are sent to server
+func fetchFeedIfModified(url, etag, lastModified string) (*fetchResult, error) {
+	result, err := fetchData(url, etag, lastModified)
+	if err != nil || result.NotModified {
+		return result, err
+	}
+
+	result.Feed, err = parseFeed(result.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// fetchData - download resource without parsing
+func fetchData(url, etag, lastModified string) (*fetchResult, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %s", err)
+	}
+
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		request.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	// get feed data
+	response, err := getFetcher().Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("get feed error: %s", err)
+	}
+
+	defer response.Body.Close()
+
+	result := &fetchResult{
+		StatusCode:   response.StatusCode,
+		MaxAge:       parseMaxAge(response.Header.Get("Cache-Control")),
+		Hub:          headerLink(response.Header, "hub"),
+		Self:         headerLink(response.Header, "self"),
+		ContentType:  response.Header.Get("Content-Type"),
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		result.ETag = etag
+		result.LastModified = lastModified
+
+		return result, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &FetchError{
+			StatusCode: response.StatusCode,
+			Message:    response.Status,
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+		}
+	}
+
+	result.Data, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read feed error: %s", err)
+	}
+
+	return result, nil
+}
+
+// parseMaxAge - get max-age from Cache-Control header
+func parseMaxAge(value string) time.Duration {
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return 0
+}
+
+// parseRetryAfter - get delay from Retry-After header, value is seconds or HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(time.Now()) {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+// headerLink - get url with relation from Link headers
+func headerLink(header http.Header, rel string) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(strings.ToLower(param), "rel=") {
+					continue
+				}
+
+				for _, value := range strings.Fields(strings.Trim(param[len("rel="):], `"`)) {
+					if strings.EqualFold(value, rel) {
+						return target
+					}
+				}
+			}
+		}
+	}
+
+	return ""
 }
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags - tags kept in article bodies with their allowed attributes
+var allowedTags = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.Audio:      {"src", "controls"},
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        nil,
+	atom.Details:    nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        nil,
+	atom.Kbd:        nil,
+	atom.Li:         nil,
+	atom.Mark:       nil,
+	atom.Ol:         nil,
+	atom.P:          nil,
+	atom.Picture:    nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Small:      nil,
+	atom.Source:     {"src", "type"},
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Summary:    nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      nil,
+	atom.Time:       {"datetime"},
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+	atom.Video:      {"src", "poster", "controls", "width", "height"},
+}
+
+// droppedTags - tags removed together with their content, other unknown tags are unwrapped
+var droppedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Textarea: true,
+	atom.Select:   true,
+	atom.Button:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Meta:     true,
+	atom.Link:     true,
+	atom.Base:     true,
+}
+
+// urlAttributes - attributes with links which are resolved and checked
+var urlAttributes = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"poster": true,
+	"cite":   true,
+}
+
+// allowedSchemes - url schemes allowed in links
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// sanitizeHTML - keep only allowed tags and attributes, relative links are resolved against base url
+func sanitizeHTML(body string, base string) string {
+	if strings.TrimSpace(body) == "" {
+		return body
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil || !baseURL.IsAbs() {
+		baseURL = nil
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return html.EscapeString(body)
+	}
+
+	var builder strings.Builder
+	for _, node := range nodes {
+		writeSafeNode(&builder, node, baseURL)
+	}
+
+	return builder.String()
+}
+
+func writeSafeNode(builder *strings.Builder, node *html.Node, base *url.URL) {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(html.EscapeString(node.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedTags[node.DataAtom] {
+		return
+	}
+
+	attributes, allowed := allowedTags[node.DataAtom]
+	if allowed {
+		builder.WriteString("<" + node.Data)
+		writeSafeAttributes(builder, node, attributes, base)
+		builder.WriteString(">")
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeSafeNode(builder, child, base)
+	}
+
+	if allowed && !isVoidElement(node.DataAtom) {
+		builder.WriteString("</" + node.Data + ">")
+	}
+}
+
+func writeSafeAttributes(builder *strings.Builder, node *html.Node, attributes []string, base *url.URL) {
+	for _, attribute := range node.Attr {
+		key := strings.ToLower(attribute.Key)
+		if attribute.Namespace != "" || !containsString(attributes, key) {
+			continue
+		}
+
+		value := attribute.Val
+		if urlAttributes[key] {
+			var ok bool
+			if value, ok = safeURL(value, base); !ok {
+				continue
+			}
+		}
+
+		builder.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+	}
+
+	if node.DataAtom == atom.A {
+		builder.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+}
+
+// resolveURL - make link absolute using base url
+func resolveURL(value string, base string) string {
+	link, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || link.IsAbs() {
+		return value
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil || !baseURL.IsAbs() {
+		return value
+	}
+
+	return baseURL.ResolveReference(link).String()
+}
+
+// safeURL - resolve url against base and check its scheme
+func safeURL(value string, base *url.URL) (string, bool) {
+	link, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		link = base.ResolveReference(link)
+	}
+	if link.Scheme == "" && link.Host == "" {
+		// relative link without base is kept as is
+		return link.String(), true
+	}
+
+	return link.String(), allowedSchemes[strings.ToLower(link.Scheme)]
+}
+
+func isVoidElement(tag atom.Atom) bool {
+	switch tag {
+	case atom.Br, atom.Hr, atom.Img, atom.Source:
+		return true
+	}
+
+	return false
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
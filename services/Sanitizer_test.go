@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	const base = "https://example.com/blog/post.html"
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", ""},
+		{"text", "a < b & c", "a &lt; b &amp; c"},
+		{"allowed tags", "<p>Hello <b>world</b></p>", "<p>Hello <b>world</b></p>"},
+		{"unknown tag is unwrapped", "<custom>text</custom>", "text"},
+
+		{"script", `<p>a</p><script>alert(1)</script><p>b</p>`, "<p>a</p><p>b</p>"},
+		{"script in paragraph", `<p>a<script type="text/javascript">alert(1)</script></p>`, "<p>a</p>"},
+		{"style", `<style>body { display: none }</style><p>text</p>`, "<p>text</p>"},
+		{"style attribute", `<p style="position: fixed">text</p>`, "<p>text</p>"},
+		{"noscript", `<noscript><img src="x.png"></noscript>text`, "text"},
+		{"iframe", `<iframe src="https://evil.com"></iframe>text`, "text"},
+		{"svg", `<svg onload="alert(1)"><script>alert(1)</script></svg>text`, "text"},
+
+		{"onclick", `<p onclick="alert(1)">text</p>`, "<p>text</p>"},
+		{"onerror", `<img src="a.png" onerror="alert(1)">`, `<img src="https://example.com/blog/a.png">`},
+		{"upper case handler", `<b ONMOUSEOVER="alert(1)">text</b>`, "<b>text</b>"},
+
+		{"javascript link", `<a href="javascript:alert(1)">link</a>`, `<a rel="nofollow noopener noreferrer">link</a>`},
+		{"javascript mixed case", `<a href=" JaVaScRiPt:alert(1)">link</a>`, `<a rel="nofollow noopener noreferrer">link</a>`},
+		{"javascript with tab", "<a href=\"java\tscript:alert(1)\">link</a>", `<a rel="nofollow noopener noreferrer">link</a>`},
+		{"vbscript link", `<a href="vbscript:msgbox(1)">link</a>`, `<a rel="nofollow noopener noreferrer">link</a>`},
+		{"data image", `<img src="data:image/png;base64,AAAA" alt="x">`, `<img alt="x">`},
+		{"data link", `<a href="data:text/html,<script>alert(1)</script>">link</a>`, `<a rel="nofollow noopener noreferrer">link</a>`},
+		{"mailto link", `<a href="mailto:user@example.com">mail</a>`, `<a href="mailto:user@example.com" rel="nofollow noopener noreferrer">mail</a>`},
+
+		{"relative link", `<a href="next.html">next</a>`, `<a href="https://example.com/blog/next.html" rel="nofollow noopener noreferrer">next</a>`},
+		{"root relative link", `<a href="/about">about</a>`, `<a href="https://example.com/about" rel="nofollow noopener noreferrer">about</a>`},
+		{"parent relative image", `<img src="../images/a.png">`, `<img src="https://example.com/images/a.png">`},
+		{"scheme relative link", `<a href="//cdn.example.org/a">a</a>`, `<a href="https://cdn.example.org/a" rel="nofollow noopener noreferrer">a</a>`},
+		{"absolute link", `<a href="http://other.org/a?b=1&amp;c=2">a</a>`, `<a href="http://other.org/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">a</a>`},
+		{"video poster", `<video src="v.mp4" poster="p.jpg"></video>`, `<video src="https://example.com/blog/v.mp4" poster="https://example.com/blog/p.jpg"></video>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeHTML(test.body, base); got != test.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", test.body, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLWithoutBase(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"relative link is kept", `<a href="/about">about</a>`, `<a href="/about" rel="nofollow noopener noreferrer">about</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">link</a>`, `<a rel="nofollow noopener noreferrer">link</a>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeHTML(test.body, ""); got != test.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", test.body, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+)
+
+// SavedSearchService - user searches shown next to feeds,
+// virtual feed of search has negative id of search
+type SavedSearchService struct {
+	db     *gorm.DB
+	config *models.Config
+}
+
+func NewSavedSearchService(config *models.Config) *SavedSearchService {
+	return &SavedSearchService{
+		db:     getDb(),
+		config: config,
+	}
+}
+
+func (service *SavedSearchService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// GetAll - get saved searches of user
+func (service *SavedSearchService) GetAll(userID int64) []models.SavedSearches {
+	var searches []models.SavedSearches
+	service.db.Where(&models.SavedSearches{UserId: userID}).Order("Name").Find(&searches)
+
+	return searches
+}
+
+// Get - get saved search of user, Id is 0 when search is not found
+func (service *SavedSearchService) Get(id int64, userID int64) models.SavedSearches {
+	search := models.SavedSearches{}
+	service.db.Where(&models.SavedSearches{Id: id, UserId: userID}).Find(&search)
+
+	return search
+}
+
+// GetFeeds - get saved searches as virtual feeds with count of unread articles
+func (service *SavedSearchService) GetFeeds(userID int64) []models.Feed {
+	searches := service.GetAll(userID)
+	feeds := make([]models.Feed, len(searches))
+
+	for i := range searches {
+		search := searches[i]
+		var count int64
+
+		searchQuery(service.db, search.Search, search.IsBookmark, search.FeedId, userID).
+			Where("articles.IsRead = ?", false).
+			Count(&count)
+
+		feeds[i] = models.Feed{
+			Feed:          models.Feeds{Id: -search.Id, Name: search.Name, UserId: userID},
+			ArticlesCount: int(count),
+			ExistUnread:   count > 0,
+			SavedSearch:   &search,
+		}
+	}
+
+	return feeds
+}
+
+// Create - save new search
+func (service *SavedSearchService) Create(data models.SavedSearchData, userID int64) (models.SavedSearches, error) {
+	search := models.SavedSearches{UserId: userID}
+
+	if err := service.fill(&search, data); err != nil {
+		return search, err
+	}
+
+	err := service.db.Create(&search).Error
+
+	return search, err
+}
+
+// Update - change saved search, nil is returned when search is not found
+func (service *SavedSearchService) Update(id int64, data models.SavedSearchData, userID int64) (*models.SavedSearches, error) {
+	search := service.Get(id, userID)
+
+	if search.Id == 0 {
+		return nil, nil
+	}
+	if err := service.fill(&search, data); err != nil {
+		return nil, err
+	}
+	if err := service.db.Save(&search).Error; err != nil {
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+// Delete - remove saved search
+func (service *SavedSearchService) Delete(id int64, userID int64) error {
+	return service.db.Where(&models.SavedSearches{Id: id, UserId: userID}).Delete(models.SavedSearches{}).Error
+}
+
+// GetArticles - get articles found by saved search, nil is returned when search is not found
+func (service *SavedSearchService) GetArticles(id int64, userID int64, page int) *models.ArticlesJSON {
+	search := service.Get(id, userID)
+
+	if search.Id == 0 {
+		return nil
+	}
+
+	var articles []models.Articles
+	var count int64
+	offset := service.config.PageSize * (page - 1)
+
+	var settings models.Settings
+	service.db.Where(models.Settings{UserId: userID}).Find(&settings)
+
+	query := func() *gorm.DB {
+		query := searchQuery(service.db, search.Search, search.IsBookmark, search.FeedId, userID)
+		if search.UnreadOnly || settings.UnreadOnly {
+			query = query.Where("articles.IsRead = ?", false)
+		}
+
+		return query
+	}
+
+	query().Select("articles.Id, articles.Title, articles.IsBookmark, articles.IsRead, articles.Link, articles.FeedId, articles.Date").
+		Limit(service.config.PageSize).
+		Offset(offset).
+		Order("articles.Date desc, articles.Id desc").
+		Find(&articles)
+	query().Count(&count)
+
+	attachTags(service.db, articles)
+
+	return &models.ArticlesJSON{Articles: articles, Count: count}
+}
+
+// fill - set fields of search from request data, feed of search must belong to user
+func (service *SavedSearchService) fill(search *models.SavedSearches, data models.SavedSearchData) error {
+	search.Name = strings.TrimSpace(data.Name)
+	search.Search = strings.TrimSpace(data.Search)
+	search.FeedId = data.FeedId
+	search.IsBookmark = data.IsBookmark
+	search.UnreadOnly = data.UnreadOnly
+
+	if search.Name == "" {
+		return errors.New("search name is empty")
+	}
+
+	if search.FeedId != 0 {
+		var count int64
+		service.db.Model(&models.Feeds{}).Where(&models.Feeds{Id: search.FeedId, UserId: search.UserId}).Count(&count)
+
+		if count == 0 {
+			return errors.New("feed of search is not found")
+		}
+	}
+
+	return nil
+}
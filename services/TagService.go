@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagService - user tags of articles
+type TagService struct {
+	db     *gorm.DB
+	config *models.Config
+}
+
+func NewTagService(config *models.Config) *TagService {
+	return &TagService{
+		db:     getDb(),
+		config: config,
+	}
+}
+
+func (service *TagService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// GetAll - get tags of user with count of tagged articles
+func (service *TagService) GetAll(userID int64) []models.Tag {
+	var tags []models.Tags
+	var counts []struct {
+		TagId int64
+		Count int64
+	}
+
+	service.db.Where(&models.Tags{UserId: userID}).Order("Name").Find(&tags)
+	service.db.Model(&models.ArticleTags{}).
+		Joins("join tags on articletags.TagId = tags.Id").
+		Select("articletags.TagId as TagId, count(*) as Count").
+		Where("tags.UserId = ?", userID).
+		Group("articletags.TagId").
+		Scan(&counts)
+
+	result := make([]models.Tag, len(tags))
+	for i, tag := range tags {
+		result[i].Tag = tag
+
+		for _, count := range counts {
+			if count.TagId == tag.Id {
+				result[i].ArticlesCount = count.Count
+			}
+		}
+	}
+
+	return result
+}
+
+// Get - get tag of user, Id is 0 when tag is not found
+func (service *TagService) Get(id int64, userID int64) models.Tags {
+	tag := models.Tags{}
+	service.db.Where(&models.Tags{Id: id, UserId: userID}).Find(&tag)
+
+	return tag
+}
+
+// Delete - remove tag from all articles
+func (service *TagService) Delete(id int64, userID int64) error {
+	tag := service.Get(id, userID)
+
+	if tag.Id == 0 {
+		return nil
+	}
+
+	return service.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&models.ArticleTags{TagId: tag.Id}).Delete(models.ArticleTags{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&tag).Error
+	})
+}
+
+// AddTag - add tag to article, tag is created when user has no tag with such name.
+// Tags of article are returned, nil if article is not found
+func (service *TagService) AddTag(articleID int64, name string, userID int64) ([]models.Tags, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return nil, errors.New("tag name is empty")
+	}
+	if !service.isUserArticle(articleID, userID) {
+		return nil, nil
+	}
+
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		return addArticleTag(tx, articleID, name, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return articleTags(service.db, []int64{articleID})[articleID], nil
+}
+
+// RemoveTag - remove tag from article, tag itself is kept.
+// Tags of article are returned, nil if article is not found
+func (service *TagService) RemoveTag(articleID int64, tagID int64, userID int64) []models.Tags {
+	if !service.isUserArticle(articleID, userID) {
+		return nil
+	}
+
+	tag := service.Get(tagID, userID)
+
+	if tag.Id != 0 {
+		service.db.Where(&models.ArticleTags{ArticleId: articleID, TagId: tag.Id}).Delete(models.ArticleTags{})
+	}
+
+	return articleTags(service.db, []int64{articleID})[articleID]
+}
+
+// GetArticles - get articles with tag
+func (service *TagService) GetArticles(id int64, userID int64, page int) *models.ArticlesJSON {
+	var articles []models.Articles
+	var count int64
+	offset := service.config.PageSize * (page - 1)
+	articleIds := service.db.Model(&models.ArticleTags{}).
+		Select("ArticleId").
+		Where("TagId = ?", service.Get(id, userID).Id)
+
+	service.db.Where("Id IN (?)", articleIds).
+		Select("Id, Title, IsBookmark, IsRead, Link, FeedId, Date").
+		Preload("Enclosures").
+		Limit(service.config.PageSize).
+		Offset(offset).
+		Order("Date desc, Id desc").
+		Find(&articles)
+	service.db.Model(&models.Articles{}).Where("Id IN (?)", articleIds).Count(&count)
+
+	attachTags(service.db, articles)
+
+	return &models.ArticlesJSON{Articles: articles, Count: count}
+}
+
+func (service *TagService) isUserArticle(articleID int64, userID int64) bool {
+	var count int64
+	service.db.Model(&models.Articles{}).
+		Joins("join feeds on articles.FeedId = feeds.Id").
+		Where("articles.Id = ? and feeds.UserId = ?", articleID, userID).
+		Count(&count)
+
+	return count > 0
+}
+
+// addArticleTag - add tag to article, tag is created when user has no tag with such name
+func addArticleTag(tx *gorm.DB, articleID int64, name string, userID int64) error {
+	tag := models.Tags{}
+	tx.Where("UserId = ? and lower(Name) = lower(?)", userID, name).Limit(1).Find(&tag)
+
+	if tag.Id == 0 {
+		// the same tag can be created by concurrent request
+		tag = models.Tags{UserId: userID, Name: name}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			tag = models.Tags{}
+			tx.Where(&models.Tags{UserId: userID, Name: name}).Find(&tag)
+		}
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ArticleTags{ArticleId: articleID, TagId: tag.Id}).
+		Error
+}
+
+// articleTags - get tags of articles by article id
+func articleTags(db *gorm.DB, ids []int64) map[int64][]models.Tags {
+	var rows []struct {
+		ArticleId int64
+		Id        int64
+		UserId    int64
+		Name      string
+	}
+
+	result := make(map[int64][]models.Tags, len(ids))
+	for _, id := range ids {
+		result[id] = []models.Tags{}
+	}
+
+	if len(ids) == 0 {
+		return result
+	}
+
+	db.Model(&models.ArticleTags{}).
+		Joins("join tags on articletags.TagId = tags.Id").
+		Select("articletags.ArticleId as ArticleId, tags.Id as Id, tags.UserId as UserId, tags.Name as Name").
+		Where("articletags.ArticleId IN (?)", ids).
+		Order("tags.Name").
+		Scan(&rows)
+
+	for _, row := range rows {
+		result[row.ArticleId] = append(result[row.ArticleId], models.Tags{Id: row.Id, UserId: row.UserId, Name: row.Name})
+	}
+
+	return result
+}
+
+// attachTags - fill tags of loaded articles
+func attachTags(db *gorm.DB, articles []models.Articles) {
+	ids := make([]int64, len(articles))
+	for i, article := range articles {
+		ids[i] = article.Id
+	}
+
+	tags := articleTags(db, ids)
+	for i := range articles {
+		articles[i].Tags = tags[articles[i].Id]
+	}
+}
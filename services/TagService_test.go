@@ -0,0 +1,123 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"newshub-server/models"
+)
+
+func createTaggedArticle(t *testing.T, userID int64) models.Articles {
+	t.Helper()
+
+	feed := models.Feeds{UserId: userID, Name: "podcast", Url: "https://example.com/podcast.xml"}
+	if err := db.Create(&feed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	article := models.Articles{
+		FeedId:     feed.Id,
+		Guid:       "1",
+		Title:      "episode",
+		Enclosures: []models.Enclosures{{Url: "https://example.com/1.mp3", Type: "audio/mpeg"}},
+	}
+	if err := db.Create(&article).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return article
+}
+
+func TestTagArticlesEnclosures(t *testing.T) {
+	config := setupTestDb(t)
+	service := NewTagService(config)
+	article := createTaggedArticle(t, 1)
+
+	tags, err := service.AddTag(article.Id, "podcasts", 1)
+	if err != nil || len(tags) != 1 {
+		t.Fatalf("tags %v, error %v", tags, err)
+	}
+
+	result := service.GetArticles(tags[0].Id, 1, 1)
+	if len(result.Articles) != 1 {
+		t.Fatalf("%d articles with tag, want 1", len(result.Articles))
+	}
+
+	enclosures := result.Articles[0].Enclosures
+	if len(enclosures) != 1 || enclosures[0].Url != "https://example.com/1.mp3" {
+		t.Errorf("enclosures %+v, want media of episode", enclosures)
+	}
+}
+
+func TestAddTagOnce(t *testing.T) {
+	config := setupTestDb(t)
+	service := NewTagService(config)
+	article := createTaggedArticle(t, 1)
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"news", "news", "News", " news ", "news"} {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			if _, err := service.AddTag(article.Id, name, 1); err != nil {
+				t.Errorf("add tag %q error: %s", name, err)
+			}
+		}(name)
+	}
+
+	wg.Wait()
+
+	var tags, links int64
+	db.Model(&models.Tags{}).Count(&tags)
+	db.Model(&models.ArticleTags{}).Count(&links)
+
+	if tags != 1 || links != 1 {
+		t.Errorf("%d tags and %d tagged articles, want 1 and 1", tags, links)
+	}
+}
+
+func TestMigrateTagNames(t *testing.T) {
+	setupTestDb(t)
+
+	if err := db.Migrator().DropIndex(&models.Tags{}, "idx_tags_user_name"); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []models.Tags{
+		{UserId: 1, Name: "news"},
+		{UserId: 1, Name: "news"},
+		{UserId: 1, Name: "news"},
+		{UserId: 2, Name: "news"},
+	}
+	db.Create(&tags)
+
+	// article 1 has all copies, article 2 has second copy, article 3 has tag of other user
+	db.Create(&[]models.ArticleTags{
+		{ArticleId: 1, TagId: tags[0].Id},
+		{ArticleId: 1, TagId: tags[1].Id},
+		{ArticleId: 1, TagId: tags[2].Id},
+		{ArticleId: 2, TagId: tags[1].Id},
+		{ArticleId: 3, TagId: tags[3].Id},
+	})
+
+	migrateTagNames(db)
+
+	var ids []int64
+	db.Model(&models.Tags{}).Order("Id").Pluck("Id", &ids)
+
+	if len(ids) != 2 || ids[0] != tags[0].Id || ids[1] != tags[3].Id {
+		t.Errorf("tags %v, want %d and %d", ids, tags[0].Id, tags[3].Id)
+	}
+
+	var links []models.ArticleTags
+	db.Order("ArticleId").Find(&links)
+
+	if len(links) != 3 || links[0].TagId != tags[0].Id || links[1].TagId != tags[0].Id || links[2].TagId != tags[3].Id {
+		t.Errorf("tagged articles %+v", links)
+	}
+	if err := db.AutoMigrate(&models.Tags{}); err != nil {
+		t.Errorf("unique index is not created: %s", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"newshub-server/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TwitterService struct {
@@ -73,6 +74,43 @@ func (service *TwitterService) Search(searchString string, sourceID int64, userI
 	return getNewsView(dbModels)
 }
 
+// AddNews - save new tweets of user, it is the only ingest path where filter rules are applied.
+// Discarded tweets and tweets already saved with the same tweet id are skipped,
+// count of saved tweets is returned
+func (service *TwitterService) AddNews(userID int64, news []models.TwitterNews) (int, error) {
+	rules := loadRules(service.db, userID, FilterSourceTwitter)
+	sources := make(map[int64]models.TwitterSource)
+
+	for _, source := range service.GetAllSources(userID) {
+		sources[source.Id] = source
+	}
+
+	saved := make([]models.TwitterNews, 0, len(news))
+	for _, tweet := range news {
+		tweet.Id = 0
+		tweet.UserId = userID
+		item := models.FilterTestItem{
+			Source: FilterSourceTwitter,
+			Body:   tweet.Text,
+			Link:   tweet.ExpandedUrl,
+			Author: sources[tweet.SourceId].ScreenName,
+			Feed:   sources[tweet.SourceId].Name,
+		}
+
+		if !isDiscarded(rules, item) {
+			saved = append(saved, tweet)
+		}
+	}
+
+	if len(saved) == 0 {
+		return 0, nil
+	}
+
+	result := service.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&saved)
+
+	return int(result.RowsAffected), result.Error
+}
+
 func getNewsView(dbModels []models.TwitterNews) []models.TwitterNewsView {
 	result := make([]models.TwitterNewsView, len(dbModels))
 
@@ -0,0 +1,630 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	feedLocks      = make(map[int64]*feedLock)
+	feedLocksMutex sync.Mutex
+)
+
+// feedLock - lock of feed with count of goroutines which use it
+type feedLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+// UpdateService - background feeds updater
+type UpdateService struct {
+	db       *gorm.DB
+	config   *models.Config
+	settings models.AppSettings
+	stop     chan struct{}
+}
+
+// NewUpdateService - create updater, interval is taken from config
+func NewUpdateService(config *models.Config) *UpdateService {
+	return &UpdateService{
+		db:       getDb(),
+		config:   config,
+		settings: models.AppSettings{UpdateMinutes: config.UpdateMinutes},
+		stop:     make(chan struct{}),
+	}
+}
+
+func (service *UpdateService) SetDb(db *gorm.DB) {
+	service.db = db
+}
+
+// checkInterval - how often feeds are checked for next fetch time
+const checkInterval = time.Minute
+
+// publishHistory - count of last articles used for publish frequency
+const publishHistory = 10
+
+// maxFullContentArticles - limit of pages downloaded for one feed update
+const maxFullContentArticles = 20
+
+// Start - run update loop in background
+func (service *UpdateService) Start() {
+	if service.settings.UpdateMinutes <= 0 {
+		log.Println("feeds update is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		service.UpdateAll()
+
+		for {
+			select {
+			case <-ticker.C:
+				service.UpdateAll()
+			case <-service.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stop update loop
+func (service *UpdateService) Stop() {
+	close(service.stop)
+}
+
+// UpdateAll - fetch all feeds with passed next fetch time using worker pool
+func (service *UpdateService) UpdateAll() {
+	var feeds []models.Feeds
+
+	err := service.db.Not(&models.Feeds{IsPaused: true}).
+		Where("NextFetch <= ?", time.Now().Unix()).
+		Find(&feeds).
+		Error
+	if err != nil {
+		log.Println("get feeds for update error:", err)
+		return
+	}
+
+	workers := service.config.UpdateWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	queue := make(chan models.Feeds)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for feed := range queue {
+				if err := service.UpdateFeed(feed); err != nil {
+					log.Printf("update feed %d (%s) error: %s", feed.Id, feed.Url, err)
+				}
+			}
+		}()
+	}
+
+	for _, feed := range feeds {
+		queue <- feed
+	}
+
+	close(queue)
+	wg.Wait()
+}
+
+// UpdateFeed - fetch one feed and insert new articles, unchanged feeds are skipped
+func (service *UpdateService) UpdateFeed(feed models.Feeds) error {
+	result, err := fetchFeedIfModified(feed.Url, feed.ETag, feed.LastModified)
+	if err != nil {
+		service.saveFailure(feed, err)
+		return err
+	}
+
+	if !result.NotModified {
+		if err := service.ingest(feed, result.Feed); err != nil {
+			service.saveFailure(feed, err)
+			return err
+		}
+
+		service.prunePurged(feed, result.Feed.Items)
+	}
+
+	ttl := 0
+	if result.Feed != nil {
+		ttl = result.Feed.TTL
+	}
+
+	now := time.Now()
+	interval := service.feedInterval(feed, ttl, result.MaxAge)
+	columns := map[string]interface{}{
+		"ETag":         result.ETag,
+		"LastModified": result.LastModified,
+		"LastFetch":    now.Unix(),
+		"LastSuccess":  now.Unix(),
+		"LastError":    "",
+		"HttpStatus":   result.StatusCode,
+		"FailCount":    0,
+		"Interval":     int(interval / time.Minute),
+		"NextFetch":    now.Add(interval).Unix(),
+	}
+
+	if result.Feed != nil && result.Feed.Link != "" && result.Feed.Link != feed.SiteUrl {
+		columns["SiteUrl"] = result.Feed.Link
+	}
+
+	// new or changed hub is subscribed by WebSub service
+	if hub, topic := feedHub(feed, result); hub != feed.HubUrl || topic != feed.HubTopic {
+		columns["HubUrl"] = hub
+		columns["HubTopic"] = topic
+		columns["HubExpires"] = 0
+		columns["HubRequested"] = 0
+	}
+
+	return service.db.Model(&feed).UpdateColumns(columns).Error
+}
+
+// feedHub - get WebSub hub and topic from response headers or feed links
+func feedHub(feed models.Feeds, result *fetchResult) (string, string) {
+	if result.Feed == nil {
+		return feed.HubUrl, feed.HubTopic
+	}
+
+	hub, topic := result.Hub, result.Self
+
+	if hub == "" {
+		hub, topic = result.Feed.Hub, result.Feed.Self
+	}
+	if hub == "" {
+		return "", ""
+	}
+	if topic == "" {
+		topic = feed.Url
+	}
+
+	return hub, topic
+}
+
+// feedInterval - get time until next fetch: interval set by user or
+// publish frequency limited by publisher hints and config bounds
+func (service *UpdateService) feedInterval(feed models.Feeds, ttl int, maxAge time.Duration) time.Duration {
+	if feed.UpdateMinutes > 0 {
+		return service.limitInterval(time.Duration(feed.UpdateMinutes)*time.Minute, false)
+	}
+
+	interval := service.publishInterval(feed.Id)
+	if interval <= 0 {
+		interval = time.Duration(service.settings.UpdateMinutes) * time.Minute
+	}
+	if hint := time.Duration(ttl) * time.Minute; hint > interval {
+		interval = hint
+	}
+	if maxAge > interval {
+		interval = maxAge
+	}
+
+	return service.limitInterval(interval, true)
+}
+
+// publishInterval - get average time between last articles of feed
+func (service *UpdateService) publishInterval(feedID int64) time.Duration {
+	var dates []int64
+
+	err := service.db.Model(&models.Articles{}).
+		Where(&models.Articles{FeedId: feedID}).
+		Order("Date desc").
+		Limit(publishHistory).
+		Pluck("Date", &dates).
+		Error
+	if err != nil || len(dates) < 2 {
+		return 0
+	}
+
+	seconds := (dates[0] - dates[len(dates)-1]) / int64(len(dates)-1)
+
+	return time.Duration(seconds) * time.Second
+}
+
+// limitInterval - apply min and max bounds from config, user intervals have only lower bound
+func (service *UpdateService) limitInterval(interval time.Duration, withMax bool) time.Duration {
+	minInterval := time.Duration(service.config.MinUpdateMinutes) * time.Minute
+	maxInterval := time.Duration(service.config.MaxUpdateMinutes) * time.Minute
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if withMax && maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	if interval < checkInterval {
+		interval = checkInterval
+	}
+
+	return interval
+}
+
+// saveFailure - update feed health, feed is paused after too many failures or when it is gone
+func (service *UpdateService) saveFailure(feed models.Feeds, updateErr error) {
+	status := 0
+	retryAfter := time.Duration(0)
+
+	if fetchErr, ok := updateErr.(*FetchError); ok {
+		status = fetchErr.StatusCode
+		retryAfter = fetchErr.RetryAfter
+	}
+
+	failCount := feed.FailCount + 1
+	if (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) && retryAfter > 0 {
+		// server asked to wait, it is not a failure of feed
+		failCount = feed.FailCount
+	}
+
+	maxFailures := service.config.MaxFeedFailures
+	isPaused := status == http.StatusGone || (maxFailures > 0 && failCount >= maxFailures)
+
+	// exponential backoff from usual interval
+	interval := time.Duration(feed.Interval) * time.Minute
+	if interval <= 0 {
+		interval = time.Duration(service.settings.UpdateMinutes) * time.Minute
+	}
+	for i := 1; i < failCount && i < 10; i++ {
+		interval *= 2
+	}
+
+	interval = service.limitInterval(interval, true)
+	if retryAfter > interval {
+		interval = retryAfter
+	}
+
+	now := time.Now()
+	err := service.db.Model(&feed).UpdateColumns(map[string]interface{}{
+		"LastFetch":  now.Unix(),
+		"LastError":  updateErr.Error(),
+		"HttpStatus": status,
+		"FailCount":  failCount,
+		"IsPaused":   isPaused,
+		"NextFetch":  now.Add(interval).Unix(),
+	}).Error
+	if err != nil {
+		log.Printf("save feed %d status error: %s", feed.Id, err)
+	}
+	if isPaused {
+		log.Printf("feed %d (%s) is paused after %d failures", feed.Id, feed.Url, failCount)
+	}
+}
+
+// ingest - sanitize and save feed items, process new articles
+func (service *UpdateService) ingest(feed models.Feeds, parsed *models.ParsedFeed) error {
+	items := parsed.Items
+	base := parsed.Link
+	if base == "" {
+		base = feed.Url
+	}
+
+	normalizeItems(items, base)
+
+	// pushed content and scheduled update of the same feed are saved one by one
+	unlock := lockFeed(feed.Id)
+	created, err := service.saveArticles(feed, items)
+	unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// pages are downloaded in background, so update worker and hub request do not wait for them
+	if feed.FullContent && len(created) > 0 {
+		if len(created) > maxFullContentArticles {
+			created = created[:maxFullContentArticles]
+		}
+
+		saveFullContentAsync(service.db, created...)
+	}
+
+	return nil
+}
+
+// prunePurged - forget guids of removed articles which are not in feed anymore.
+// Pushed content can have only new items, so guids are pruned after full fetch only
+func (service *UpdateService) prunePurged(feed models.Feeds, items []models.ParsedItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	guids := make([]string, 0, len(items))
+	for _, item := range items {
+		guids = append(guids, itemGuid(item))
+	}
+
+	err := service.db.Where("FeedId = ? and Guid NOT IN ?", feed.Id, guids).Delete(models.PurgedArticles{}).Error
+	if err != nil {
+		log.Printf("prune purged articles of feed %d error: %s", feed.Id, err)
+	}
+}
+
+// lockFeed - lock saving of feed items, function to unlock is returned
+func lockFeed(feedID int64) func() {
+	feedLocksMutex.Lock()
+	lock, ok := feedLocks[feedID]
+	if !ok {
+		lock = &feedLock{}
+		feedLocks[feedID] = lock
+	}
+	lock.users++
+	feedLocksMutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+
+		feedLocksMutex.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(feedLocks, feedID)
+		}
+		feedLocksMutex.Unlock()
+	}
+}
+
+// normalizeItems - resolve relative links of items, remove unsafe links and sanitize bodies
+func normalizeItems(items []models.ParsedItem, base string) {
+	for i := range items {
+		items[i].Link = resolveURL(items[i].Link, base)
+		if _, ok := safeURL(items[i].Link, nil); !ok {
+			items[i].Link = ""
+		}
+
+		items[i].Body = sanitizeHTML(items[i].Body, items[i].Link)
+
+		enclosures := make([]models.ParsedEnclosure, 0, len(items[i].Enclosures))
+		for _, enclosure := range items[i].Enclosures {
+			enclosure.Url = resolveURL(enclosure.Url, base)
+			if _, ok := safeURL(enclosure.Url, nil); ok {
+				enclosures = append(enclosures, enclosure)
+			}
+		}
+
+		items[i].Enclosures = enclosures
+	}
+}
+
+// saveArticles - insert new items and update changed ones, items are matched by guid.
+// Created articles are returned
+func (service *UpdateService) saveArticles(feed models.Feeds, items []models.ParsedItem) ([]models.Articles, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	guids := make([]string, 0, len(items))
+	links := make([]string, 0, len(items))
+
+	for i := range items {
+		items[i].Guid = itemGuid(items[i])
+		guids = append(guids, items[i].Guid)
+		links = append(links, items[i].Link)
+	}
+
+	var articles []models.Articles
+
+	err := service.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Articles
+		err := tx.Where("FeedId = ? and Guid IN ?", feed.Id, guids).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		byGuid := make(map[string]models.Articles, len(existing))
+		for _, article := range existing {
+			byGuid[article.Guid] = article
+		}
+
+		// articles created before guid support have id as guid
+		var legacy []models.Articles
+		err = tx.Where("FeedId = ? and Link IN ?", feed.Id, links).Order("Id").Find(&legacy).Error
+		if err != nil {
+			return err
+		}
+
+		byLink := make(map[string]models.Articles, len(legacy))
+		for _, article := range legacy {
+			if _, ok := byLink[article.Link]; !ok && article.Guid == strconv.FormatInt(article.Id, 10) {
+				byLink[article.Link] = article
+			}
+		}
+
+		// articles removed by retention are not added again
+		var purgedGuids []string
+		err = tx.Model(&models.PurgedArticles{}).Where("FeedId = ? and Guid IN ?", feed.Id, guids).Pluck("Guid", &purgedGuids).Error
+		if err != nil {
+			return err
+		}
+
+		purged := make(map[string]bool, len(purgedGuids))
+		for _, guid := range purgedGuids {
+			purged[guid] = true
+		}
+
+		articles = make([]models.Articles, 0, len(items))
+		seen := make(map[string]bool, len(items))
+		now := time.Now()
+
+		for _, item := range items {
+			if seen[item.Guid] {
+				continue
+			}
+
+			seen[item.Guid] = true
+			article, ok := byGuid[item.Guid]
+
+			if !ok {
+				article, ok = byLink[item.Link]
+				delete(byLink, item.Link)
+			}
+			if ok {
+				if err := updateArticle(tx, article, item); err != nil {
+					return err
+				}
+				continue
+			}
+			if purged[item.Guid] {
+				continue
+			}
+
+			enclosures := make([]models.Enclosures, 0, len(item.Enclosures))
+			for _, enclosure := range item.Enclosures {
+				enclosures = append(enclosures, models.Enclosures{
+					Url:      enclosure.Url,
+					Type:     enclosure.Type,
+					Length:   enclosure.Length,
+					Duration: enclosure.Duration,
+				})
+			}
+
+			articles = append(articles, models.Articles{
+				FeedId:     feed.Id,
+				Guid:       item.Guid,
+				Title:      item.Title,
+				Body:       item.Body,
+				Link:       item.Link,
+				Author:     item.Author,
+				Date:       parseDate(item.Date, now).Unix(),
+				Enclosures: enclosures,
+			})
+		}
+
+		var tags [][]string
+		articles, tags = filterArticles(tx, feed, articles)
+		if len(articles) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&articles).Error; err != nil {
+			return err
+		}
+
+		for i, names := range tags {
+			for _, name := range names {
+				if err := addArticleTag(tx, articles[i].Id, name, feed.UserId); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+// updateArticle - update edited article in place, read and bookmark state is kept
+func updateArticle(tx *gorm.DB, article models.Articles, item models.ParsedItem) error {
+	if err := updateEnclosures(tx, article.Id, item.Enclosures); err != nil {
+		return err
+	}
+
+	if article.Guid == item.Guid && article.Title == item.Title &&
+		article.Body == item.Body && article.Link == item.Link {
+		return nil
+	}
+
+	return tx.Model(&article).UpdateColumns(map[string]interface{}{
+		"Guid":  item.Guid,
+		"Title": item.Title,
+		"Body":  item.Body,
+		"Link":  item.Link,
+	}).Error
+}
+
+// updateEnclosures - sync enclosures of edited article, enclosures are matched by url to keep playback positions
+func updateEnclosures(tx *gorm.DB, articleID int64, items []models.ParsedEnclosure) error {
+	var existing []models.Enclosures
+	if err := tx.Where(&models.Enclosures{ArticleId: articleID}).Order("Id").Find(&existing).Error; err != nil {
+		return err
+	}
+
+	byURL := make(map[string]models.Enclosures, len(existing))
+	for _, enclosure := range existing {
+		if _, ok := byURL[enclosure.Url]; !ok {
+			byURL[enclosure.Url] = enclosure
+		}
+	}
+
+	kept := make(map[int64]bool, len(items))
+
+	for _, item := range items {
+		enclosure, ok := byURL[item.Url]
+		if ok && kept[enclosure.Id] {
+			continue
+		}
+
+		if !ok {
+			enclosure = models.Enclosures{
+				ArticleId: articleID,
+				Url:       item.Url,
+				Type:      item.Type,
+				Length:    item.Length,
+				Duration:  item.Duration,
+			}
+			if err := tx.Create(&enclosure).Error; err != nil {
+				return err
+			}
+			byURL[item.Url] = enclosure
+		} else if enclosure.Type != item.Type || enclosure.Length != item.Length || enclosure.Duration != item.Duration {
+			err := tx.Model(&enclosure).UpdateColumns(map[string]interface{}{
+				"Type":     item.Type,
+				"Length":   item.Length,
+				"Duration": item.Duration,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		kept[enclosure.Id] = true
+	}
+
+	removed := make([]int64, 0, len(existing))
+	for _, enclosure := range existing {
+		if !kept[enclosure.Id] {
+			removed = append(removed, enclosure.Id)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := tx.Where("EnclosureId IN ?", removed).Delete(models.PlaybackPositions{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("Id IN ?", removed).Delete(models.Enclosures{}).Error
+}
+
+// itemGuid - get unique item id, hash of link and title is used when feed has no ids
+func itemGuid(item models.ParsedItem) string {
+	if item.Guid != "" {
+		return item.Guid
+	}
+
+	hash := sha1.Sum([]byte(item.Link + "\n" + item.Title))
+
+	return hex.EncodeToString(hash[:])
+}
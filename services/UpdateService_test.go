@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"newshub-server/models"
+)
+
+func TestUpdateArticleEnclosures(t *testing.T) {
+	config := setupTestDb(t)
+	feed := models.Feeds{UserId: 1, Name: "podcast", Url: "https://example.com/podcast.xml"}
+	db.Create(&feed)
+
+	item := models.ParsedItem{
+		Guid:  "1",
+		Title: "episode",
+		Link:  "https://example.com/1",
+		Enclosures: []models.ParsedEnclosure{
+			{Url: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 100},
+			{Url: "https://example.com/1.jpg", Type: "image/jpeg"},
+		},
+	}
+
+	updater := NewUpdateService(config)
+	created, err := updater.saveArticles(feed, []models.ParsedItem{item})
+	if err != nil || len(created) != 1 {
+		t.Fatalf("%d articles are created, error %v", len(created), err)
+	}
+
+	rss := NewRssService(config)
+	audio, image := created[0].Enclosures[0], created[0].Enclosures[1]
+	rss.SavePlayback(1, models.PlaybackUpdateData{EnclosureId: audio.Id, Position: 30})
+	rss.SavePlayback(1, models.PlaybackUpdateData{EnclosureId: image.Id, Position: 5})
+
+	// audio is replaced by bigger file on same url, image is removed and video is added
+	item.Enclosures = []models.ParsedEnclosure{
+		{Url: "https://example.com/1.mp3", Type: "audio/mpeg", Length: 200, Duration: 60},
+		{Url: "https://example.com/1.mp4", Type: "video/mp4"},
+		{Url: "https://example.com/1.mp4", Type: "video/mp4"},
+	}
+
+	if _, err := updater.saveArticles(feed, []models.ParsedItem{item}); err != nil {
+		t.Fatal(err)
+	}
+
+	var enclosures []models.Enclosures
+	db.Where(&models.Enclosures{ArticleId: created[0].Id}).Order("Id").Find(&enclosures)
+
+	if len(enclosures) != 2 || enclosures[0].Id != audio.Id || enclosures[1].Url != "https://example.com/1.mp4" {
+		t.Fatalf("enclosures %+v, want audio with same id and video", enclosures)
+	}
+	if enclosures[0].Length != 200 || enclosures[0].Duration != 60 {
+		t.Errorf("audio %+v is not updated", enclosures[0])
+	}
+
+	if position := rss.GetPlayback(audio.Id, 1); position == nil || position.Position != 30 {
+		t.Errorf("playback position %+v of audio is not kept", position)
+	}
+
+	var positions int64
+	db.Model(&models.PlaybackPositions{}).Where(&models.PlaybackPositions{EnclosureId: image.Id}).Count(&positions)
+	if positions != 0 {
+		t.Errorf("%d playback positions of removed image", positions)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"newshub-server/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // VkService - service
@@ -65,3 +66,40 @@ func (service *VkService) Search(searchString string, groupID int64, userID int6
 
 	return result
 }
+
+// AddNews - save new posts of user, it is the only ingest path where filter rules are applied.
+// Discarded posts and posts already saved with the same group and post id are skipped,
+// count of saved posts is returned
+func (service *VkService) AddNews(userID int64, news []models.VkNews) (int, error) {
+	rules := loadRules(service.db, userID, FilterSourceVk)
+	groups := make(map[int64]string)
+
+	for _, group := range service.GetAllGroups(userID) {
+		groups[group.Gid] = group.Name
+	}
+
+	saved := make([]models.VkNews, 0, len(news))
+	for _, post := range news {
+		post.Id = 0
+		post.UserId = userID
+		item := models.FilterTestItem{
+			Source: FilterSourceVk,
+			Body:   post.Text,
+			Link:   post.Link,
+			Author: groups[post.GroupId],
+			Feed:   groups[post.GroupId],
+		}
+
+		if !isDiscarded(rules, item) {
+			saved = append(saved, post)
+		}
+	}
+
+	if len(saved) == 0 {
+		return 0, nil
+	}
+
+	result := service.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&saved)
+
+	return int(result.RowsAffected), result.Error
+}
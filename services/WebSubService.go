@@ -0,0 +1,284 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"newshub-server/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// hubRenewInterval - how often subscriptions are checked for renewal
+	hubRenewInterval = 10 * time.Minute
+	// hubRenewBefore - subscription is renewed when lease ends earlier
+	hubRenewBefore = 24 * time.Hour
+	// hubRetryInterval - pause between subscription requests to the same hub
+	hubRetryInterval = time.Hour
+	// hubVerifyTimeout - subscription request can be verified by hub during this time
+	hubVerifyTimeout = time.Hour
+)
+
+// WebSubService - WebSub (PubSubHubbub) subscriber
+type WebSubService struct {
+	db      *gorm.DB
+	config  *models.Config
+	updater *UpdateService
+	stop    chan struct{}
+}
+
+// NewWebSubService - create subscriber, callbacks are built from public url in config
+func NewWebSubService(config *models.Config) *WebSubService {
+	return &WebSubService{
+		db:      getDb(),
+		config:  config,
+		updater: NewUpdateService(config),
+		stop:    make(chan struct{}),
+	}
+}
+
+func (service *WebSubService) SetDb(db *gorm.DB) {
+	service.db = db
+	service.updater.SetDb(db)
+}
+
+// Start - run subscription renewal loop in background
+func (service *WebSubService) Start() {
+	if service.config.PublicUrl == "" {
+		log.Println("WebSub is disabled, public url is not set")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(hubRenewInterval)
+		defer ticker.Stop()
+
+		service.RenewAll()
+
+		for {
+			select {
+			case <-ticker.C:
+				service.RenewAll()
+			case <-service.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stop renewal loop
+func (service *WebSubService) Stop() {
+	close(service.stop)
+}
+
+// RenewAll - subscribe new feeds and renew expiring subscriptions
+func (service *WebSubService) RenewAll() {
+	var feeds []models.Feeds
+	now := time.Now()
+
+	err := service.db.Not(&models.Feeds{IsPaused: true}).
+		Where("HubUrl <> ? and HubExpires < ? and HubRequested < ?",
+			"", now.Add(hubRenewBefore).Unix(), now.Add(-hubRetryInterval).Unix()).
+		Find(&feeds).
+		Error
+	if err != nil {
+		log.Println("get feeds for WebSub renewal error:", err)
+		return
+	}
+
+	for _, feed := range feeds {
+		if err := service.Subscribe(feed); err != nil {
+			log.Printf("WebSub subscribe feed %d on %s error: %s", feed.Id, feed.HubUrl, err)
+		}
+	}
+}
+
+// Subscribe - send subscription request to hub, subscription is active after verification
+func (service *WebSubService) Subscribe(feed models.Feeds) error {
+	secret, err := newHubSecret()
+	if err != nil {
+		return err
+	}
+
+	// new secret is saved before request because hub can verify subscription before response,
+	// content is checked with previous secret until verification
+	err = service.db.Model(&feed).UpdateColumns(map[string]interface{}{
+		"HubNewSecret": secret,
+		"HubRequested": time.Now().Unix(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// hub gets new secret
+	feed.HubSecret = secret
+
+	return hubRequest(service.config, feed, "subscribe")
+}
+
+// Verify - check verification request of hub, lease end is saved for confirmed subscriptions.
+// Callback is public, so only pending subscription request can be confirmed
+func (service *WebSubService) Verify(feedID int64, mode, topic string, leaseSeconds int) bool {
+	feed := models.Feeds{}
+	service.db.Where(&models.Feeds{Id: feedID}).First(&feed)
+
+	switch mode {
+	case "subscribe":
+		if feed.Id == 0 || feed.HubTopic != topic || !isHubRequestPending(feed) {
+			return false
+		}
+		// lease can not be longer than requested one
+		if leaseSeconds <= 0 || leaseSeconds > service.config.WebSubLease {
+			leaseSeconds = service.config.WebSubLease
+		}
+
+		// secret of requested subscription is used after its verification only
+		err := service.db.Model(&feed).UpdateColumns(map[string]interface{}{
+			"HubSecret":    feed.HubNewSecret,
+			"HubNewSecret": "",
+			"HubRequested": 0,
+			"HubExpires":   time.Now().Add(time.Duration(leaseSeconds) * time.Second).Unix(),
+		}).Error
+		if err != nil {
+			log.Println("save WebSub lease error:", err)
+			return false
+		}
+
+		return true
+	case "unsubscribe":
+		// feed is deleted or hub is changed
+		return feed.Id == 0 || feed.HubTopic != topic
+	case "denied":
+		if feed.Id == 0 || feed.HubTopic != topic {
+			return false
+		}
+
+		log.Printf("WebSub subscription of feed %d is denied by hub", feed.Id)
+		service.db.Model(&feed).UpdateColumns(map[string]interface{}{"HubExpires": 0, "HubNewSecret": ""})
+
+		return true
+	default:
+		return false
+	}
+}
+
+// Receive - check signature of pushed content and save new articles
+func (service *WebSubService) Receive(feedID int64, signature string, data []byte) error {
+	feed := models.Feeds{}
+	service.db.Where(&models.Feeds{Id: feedID}).First(&feed)
+
+	if feed.Id == 0 || feed.HubSecret == "" {
+		return errors.New("feed is not subscribed")
+	}
+	if !checkHubSignature(feed.HubSecret, signature, data) {
+		return errors.New("invalid signature")
+	}
+
+	parsed, err := parseFeed(data)
+	if err != nil {
+		return err
+	}
+
+	return service.updater.ingest(feed, parsed)
+}
+
+// isHubRequestPending - subscription is requested recently and is not verified yet
+func isHubRequestPending(feed models.Feeds) bool {
+	return feed.HubNewSecret != "" && feed.HubRequested > time.Now().Add(-hubVerifyTimeout).Unix()
+}
+
+// hubRequest - send subscribe or unsubscribe request to hub of feed
+func hubRequest(config *models.Config, feed models.Feeds, mode string) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {feed.HubTopic},
+		"hub.callback": {hubCallback(config, feed.Id)},
+	}
+
+	if mode == "subscribe" {
+		form.Set("hub.secret", feed.HubSecret)
+		form.Set("hub.lease_seconds", strconv.Itoa(config.WebSubLease))
+	}
+
+	response, err := getFetcher().PostForm(feed.HubUrl, form)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("hub response status %s", response.Status)
+	}
+
+	return nil
+}
+
+// unsubscribeHub - cancel subscription of deleted feed
+func unsubscribeHub(config *models.Config, feed models.Feeds) {
+	if config.PublicUrl == "" || feed.HubUrl == "" || (feed.HubSecret == "" && feed.HubNewSecret == "") {
+		return
+	}
+	if err := hubRequest(config, feed, "unsubscribe"); err != nil {
+		log.Printf("WebSub unsubscribe feed %d error: %s", feed.Id, err)
+	}
+}
+
+func hubCallback(config *models.Config, feedID int64) string {
+	return strings.TrimRight(config.PublicUrl, "/") + "/websub/" + strconv.FormatInt(feedID, 10)
+}
+
+func newHubSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// checkHubSignature - check X-Hub-Signature header, value is "method=hex"
+func checkHubSignature(secret, signature string, data []byte) bool {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	var newHash func() hash.Hash
+
+	switch strings.ToLower(parts[0]) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(data)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"newshub-server/models"
+)
+
+const testTopic = "https://example.com/feed.xml"
+
+func setupWebSub(t *testing.T) *WebSubService {
+	config := setupTestDb(t)
+	config.WebSubLease = 864000
+
+	return NewWebSubService(config)
+}
+
+func createHubFeed(t *testing.T, secret string) models.Feeds {
+	feed := models.Feeds{
+		UserId:     1,
+		Name:       "feed",
+		Url:        testTopic,
+		HubUrl:     "https://hub.example.com/",
+		HubTopic:   testTopic,
+		HubSecret:  secret,
+		HubExpires: time.Now().Add(time.Hour).Unix(),
+	}
+	if err := db.Create(&feed).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return feed
+}
+
+func getFeed(id int64) models.Feeds {
+	feed := models.Feeds{}
+	db.Where(&models.Feeds{Id: id}).Find(&feed)
+
+	return feed
+}
+
+func hubSignature(secret string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func testRss(guids ...string) []byte {
+	var items strings.Builder
+	for _, guid := range guids {
+		fmt.Fprintf(&items, "<item><guid>%s</guid><title>item %s</title><link>https://example.com/%s</link></item>", guid, guid, guid)
+	}
+
+	return []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>feed</title><link>https://example.com/</link>` +
+		items.String() + `</channel></rss>`)
+}
+
+func articleCount(feedID int64) int64 {
+	var count int64
+	db.Model(&models.Articles{}).Where(&models.Articles{FeedId: feedID}).Count(&count)
+
+	return count
+}
+
+func TestWebSubVerify(t *testing.T) {
+	service := setupWebSub(t)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		mode      string
+		topic     string
+		unknown   bool
+		requested time.Time
+		lease     int
+		verified  bool
+		expires   time.Duration
+	}{
+		{"subscribe", "subscribe", testTopic, false, now, 3600, true, time.Hour},
+		{"subscribe without lease", "subscribe", testTopic, false, now, 0, true, 240 * time.Hour},
+		{"subscribe with too long lease", "subscribe", testTopic, false, now, 100 * 864000, true, 240 * time.Hour},
+		{"subscribe without request", "subscribe", testTopic, false, time.Time{}, 3600, false, time.Hour},
+		{"subscribe old request", "subscribe", testTopic, false, now.Add(-2 * hubVerifyTimeout), 3600, false, time.Hour},
+		{"subscribe other topic", "subscribe", "https://example.com/other.xml", false, now, 3600, false, time.Hour},
+		{"subscribe unknown feed", "subscribe", testTopic, true, now, 3600, false, time.Hour},
+		{"unsubscribe active feed", "unsubscribe", testTopic, false, time.Time{}, 0, false, time.Hour},
+		{"unsubscribe old topic", "unsubscribe", "https://example.com/old.xml", false, time.Time{}, 0, true, time.Hour},
+		{"unsubscribe deleted feed", "unsubscribe", testTopic, true, time.Time{}, 0, true, time.Hour},
+		{"denied", "denied", testTopic, false, time.Time{}, 0, true, 0},
+		{"denied other topic", "denied", "https://example.com/other.xml", false, time.Time{}, 0, false, time.Hour},
+		{"denied unknown feed", "denied", testTopic, true, time.Time{}, 0, false, time.Hour},
+		{"unknown mode", "refresh", testTopic, false, now, 3600, false, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feed := createHubFeed(t, "secret")
+			feedID := feed.Id
+			if test.unknown {
+				feedID += 1000
+			}
+			if !test.requested.IsZero() {
+				db.Model(&feed).UpdateColumns(map[string]interface{}{"HubNewSecret": "new", "HubRequested": test.requested.Unix()})
+			}
+
+			if verified := service.Verify(feedID, test.mode, test.topic, test.lease); verified != test.verified {
+				t.Errorf("verified: %t, want %t", verified, test.verified)
+			}
+
+			saved := getFeed(feed.Id)
+			expires := time.Duration(saved.HubExpires-time.Now().Unix()) * time.Second
+			if test.expires == 0 && saved.HubExpires != 0 || test.expires != 0 && (expires < test.expires-time.Minute || expires > test.expires) {
+				t.Errorf("subscription expires in %s, want %s", expires, test.expires)
+			}
+
+			if test.mode == "subscribe" && test.verified && (saved.HubSecret != "new" || saved.HubNewSecret != "" || saved.HubRequested != 0) {
+				t.Errorf("request is still pending after verification")
+			}
+			if !test.verified && (saved.HubSecret != "secret" || saved.HubExpires != feed.HubExpires) {
+				t.Errorf("subscription is changed by rejected request")
+			}
+		})
+	}
+}
+
+func TestWebSubPushDuringUpdate(t *testing.T) {
+	service := setupWebSub(t)
+	feed := createHubFeed(t, "secret")
+	guids := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(guids))
+
+	for i := range guids {
+		wg.Add(2)
+
+		// pushed content has new items, fetched feed has all items
+		go func(data []byte) {
+			defer wg.Done()
+			errs <- service.Receive(feed.Id, hubSignature("secret", data), data)
+		}(testRss(guids[i]))
+
+		go func() {
+			defer wg.Done()
+
+			parsed, err := parseFeed(testRss(guids...))
+			if err == nil {
+				err = service.updater.ingest(feed, parsed)
+			}
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("save error: %s", err)
+		}
+	}
+
+	if count := articleCount(feed.Id); count != int64(len(guids)) {
+		t.Errorf("%d articles are saved, want %d", count, len(guids))
+	}
+
+	feedLocksMutex.Lock()
+	defer feedLocksMutex.Unlock()
+
+	if len(feedLocks) != 0 {
+		t.Errorf("%d feed locks are not removed", len(feedLocks))
+	}
+}